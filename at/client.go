@@ -7,6 +7,7 @@ import (
 	"log/slog"
 
 	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/api/bsky"
 
 	"github.com/bluesky-social/indigo/api/agnostic"
 	"github.com/bluesky-social/indigo/atproto/atclient"
@@ -15,9 +16,9 @@ import (
 )
 
 type Record struct {
-	Uri   string
-	Cid   string
-	Value *json.RawMessage
+	Uri   string           `json:"uri"`
+	Cid   string           `json:"cid"`
+	Value *json.RawMessage `json:"value"`
 }
 
 func (r *Record) Collection() string {
@@ -56,6 +57,8 @@ type RepoWithIdentity struct {
 type RecordsWithIdentity struct {
 	Identity *identity.Identity
 	Records  []*Record
+	// Cursor is set when more records are available
+	Cursor string
 }
 
 func (r *RecordsWithIdentity) Collection() string {
@@ -65,26 +68,40 @@ func (r *RecordsWithIdentity) Collection() string {
 	return r.Records[0].Collection()
 }
 
+// Actor is an account suggested by the AppView's typeahead search.
+type Actor struct {
+	DID         string
+	Handle      string
+	DisplayName string
+}
+
 type RecordWithIdentity struct {
 	Identity *identity.Identity
 	Record   *Record
 }
 
 type Client struct {
-	dir identity.Directory
-	c   *atclient.APIClient
+	dir     identity.Directory
+	c       *atclient.APIClient
+	appview *atclient.APIClient
 }
 
-func NewClient(service string) *Client {
+const DefaultAppView = "https://public.api.bsky.app"
+
+func NewClient(service, appview string) *Client {
 	dir := &identity.BaseDirectory{}
 	cacheDir := identity.NewCacheDirectory(dir, 0, 0, 0, 0)
 	if service == "" {
 		service = "https://bsky.social"
 	}
+	if appview == "" {
+		appview = DefaultAppView
+	}
 	client := atclient.NewAPIClient(service)
 	return &Client{
-		dir: cacheDir,
-		c:   client,
+		dir:     cacheDir,
+		c:       client,
+		appview: atclient.NewAPIClient(appview),
 	}
 }
 
@@ -127,13 +144,13 @@ func (c *Client) GetRepo(ctx context.Context, repo string) (*RepoWithIdentity, e
 	}, nil
 }
 
-func (c *Client) ListRecords(ctx context.Context, collection, repo string) (*RecordsWithIdentity, error) {
+func (c *Client) ListRecords(ctx context.Context, collection, repo, cursor string) (*RecordsWithIdentity, error) {
 	client, id, err := c.withIdentifier(ctx, repo)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client with identifier: %w", err)
 	}
 
-	resp, err := agnostic.RepoListRecords(ctx, client, collection, "", 100, repo, false)
+	resp, err := agnostic.RepoListRecords(ctx, client, collection, cursor, 100, repo, false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list records: %w", err)
 	}
@@ -143,9 +160,15 @@ func (c *Client) ListRecords(ctx context.Context, collection, repo string) (*Rec
 		records[i] = NewRecordFromList(r)
 	}
 
+	next := ""
+	if resp.Cursor != nil && len(records) > 0 {
+		next = *resp.Cursor
+	}
+
 	return &RecordsWithIdentity{
 		Identity: id,
 		Records:  records,
+		Cursor:   next,
 	}, nil
 }
 
@@ -165,3 +188,31 @@ func (c *Client) GetRecord(ctx context.Context, collection, repo, rkey string) (
 		Record:   NewRecordFromGet(resp),
 	}, nil
 }
+
+func (c *Client) SearchActorsTypeahead(ctx context.Context, q string, limit int64) ([]Actor, error) {
+	resp, err := bsky.ActorSearchActorsTypeahead(ctx, c.appview, limit, q, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to search actors: %w", err)
+	}
+	actors := make([]Actor, len(resp.Actors))
+	for i, a := range resp.Actors {
+		actors[i] = Actor{DID: a.Did, Handle: a.Handle}
+		if a.DisplayName != nil {
+			actors[i].DisplayName = *a.DisplayName
+		}
+	}
+	return actors, nil
+}
+
+// GetRepoCAR downloads the full repo as a CAR file.
+func (c *Client) GetRepoCAR(ctx context.Context, repo string) ([]byte, error) {
+	client, id, err := c.withIdentifier(ctx, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client with identifier: %w", err)
+	}
+	car, err := comatproto.SyncGetRepo(ctx, client, id.DID.String(), "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repo: %w", err)
+	}
+	return car, nil
+}
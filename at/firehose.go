@@ -0,0 +1,466 @@
+package at
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/atdata"
+	"github.com/bluesky-social/jetstream/pkg/models"
+	"github.com/gorilla/websocket"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	cbg "github.com/whyrusleeping/cbor-gen"
+)
+
+// DefaultFirehoseHost is the relay subscribed to when no host is given.
+const DefaultFirehoseHost = "relay1.us-east.bsky.network"
+
+const subscribeReposPath = "/xrpc/com.atproto.sync.subscribeRepos"
+
+// EventKindSync is the kind of #sync messages, which JetStream does not
+// forward.
+const EventKindSync = "sync"
+
+// EventKindError is the kind of firehose frames whose header could not be
+// decoded.
+const EventKindError = "error"
+
+// Frame header ops of event streams.
+const (
+	frameOpError   = -1
+	frameOpMessage = 1
+)
+
+// FirehoseInfo holds the details of a subscribeRepos message that JetStream
+// leaves out. The operations of a commit become one event each, sharing it.
+type FirehoseInfo struct {
+	Seq  int64  `json:"seq"`
+	Type string `json:"type"`
+	Time string `json:"time"`
+	Rev  string `json:"rev,omitempty"`
+	// Since is the rev of the previous commit
+	Since string `json:"since,omitempty"`
+	// Commit is the CID of the signed commit block
+	Commit string `json:"commit,omitempty"`
+	// PrevData is the MST root CID of the previous commit
+	PrevData string `json:"prevData,omitempty"`
+	// Blocks are the CIDs of the blocks in the CAR slice, in order
+	Blocks     []string     `json:"blocks,omitempty"`
+	BlocksSize int          `json:"blocksSize,omitempty"`
+	Blobs      []string     `json:"blobs,omitempty"`
+	Ops        []FirehoseOp `json:"ops,omitempty"`
+	TooBig     bool         `json:"tooBig,omitempty"`
+	Rebase     bool         `json:"rebase,omitempty"`
+	// Error is why the message, or some of its records, could not be
+	// decoded
+	Error string `json:"error,omitempty"`
+	// Verify is set for commits and syncs checked while verifying
+	Verify *Verification `json:"verify,omitempty"`
+}
+
+type FirehoseOp struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	CID    string `json:"cid,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+}
+
+// FirehoseURL returns the subscribeRepos endpoint of a relay or PDS, given
+// as a host or URL.
+func FirehoseURL(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if host == "" {
+		host = DefaultFirehoseHost
+	}
+	if !strings.Contains(host, "://") {
+		host = "wss://" + host
+	}
+	u, err := url.Parse(host)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid firehose host %q", host)
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	case "wss", "ws":
+	default:
+		return "", fmt.Errorf("invalid firehose scheme %q", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = subscribeReposPath
+	}
+	return u.String(), nil
+}
+
+// SetFirehose makes the next connections read the subscribeRepos endpoint
+// at u instead of JetStream, or JetStream again when u is empty.
+func (c *JetStreamClient) SetFirehose(u string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.firehose = u
+}
+
+// Firehose returns the subscribeRepos endpoint read instead of JetStream,
+// if any.
+func (c *JetStreamClient) Firehose() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.firehose
+}
+
+// firehoseFilter applies JetStream's filters to the firehose, which has
+// none of its own.
+type firehoseFilter struct {
+	collections []string
+	dids        []string
+}
+
+func (f *firehoseFilter) wants(evt *Event) bool {
+	// messages that failed to decode may lack what the filters match on
+	if evt.Firehose != nil && evt.Firehose.Error != "" && evt.Commit == nil {
+		return true
+	}
+	if len(f.dids) > 0 && !slices.Contains(f.dids, evt.Did) {
+		return false
+	}
+	if len(f.collections) == 0 || evt.Kind != models.EventKindCommit {
+		return true
+	}
+	if evt.Commit == nil {
+		return false
+	}
+	for _, cx := range f.collections {
+		if prefix, ok := strings.CutSuffix(cx, "*"); ok && strings.HasPrefix(evt.Commit.Collection, prefix) {
+			return true
+		}
+		if cx == evt.Commit.Collection {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *JetStreamClient) readFirehose(ctx context.Context, s *Stream, u string, cxs, dids []string, cursor *int64) error {
+	if cursor != nil {
+		u += fmt.Sprintf("?cursor=%d", *cursor)
+	}
+	c.filter.Store(&firehoseFilter{collections: cxs, dids: dids})
+	c.verifier.rewind(cursor)
+
+	c.log.Info("connecting to firehose", "url", u)
+	header := http.Header{"User-Agent": {"attie/0.0.1"}}
+	con, _, err := websocket.DefaultDialer.DialContext(ctx, u, header)
+	if err != nil {
+		return fmt.Errorf("failed to connect to firehose: %w", err)
+	}
+	select {
+	case s.connected <- struct{}{}:
+	default:
+	}
+
+	// unblock the read when stopped
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			con.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		_, msg, err := con.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			con.Close()
+			return fmt.Errorf("failed to read from firehose: %w", err)
+		}
+		c.received.Add(int64(len(msg)))
+		c.decoded.Add(int64(len(msg)))
+		f, err := decodeFrame(msg)
+		if err != nil {
+			// an error frame from the server, which ends the stream
+			con.Close()
+			return err
+		}
+		if f == nil {
+			continue
+		}
+		filter := c.filter.Load()
+		evts := slices.DeleteFunc(f.evts, func(evt *Event) bool { return !filter.wants(evt) })
+		// the frames of shown events are recorded as received, with all
+		// of their operations
+		if rec := c.recorder.Load(); rec != nil && len(evts) > 0 {
+			if err := rec.WriteFrame(msg); err != nil {
+				c.log.Error("failed to record frame", "error", err)
+			}
+		}
+		if c.verify.Load() {
+			// chains are followed for every repo, proofs and signatures
+			// only checked for shown events
+			var info *FirehoseInfo
+			if len(evts) > 0 {
+				// shared by the events of a commit
+				info = evts[0].Firehose
+			}
+			c.verifier.verify(ctx, f, info, c.verified)
+		}
+		for _, evt := range evts {
+			if err := c.handleEvent(ctx, s, evt); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// frame is a decoded subscribeRepos message and its events.
+type frame struct {
+	evts   []*Event
+	commit *comatproto.SyncSubscribeRepos_Commit
+	sync   *comatproto.SyncSubscribeRepos_Sync
+	// DID of an #identity message
+	identity string
+}
+
+// decodeFrame decodes a subscribeRepos frame into events. Frames of other
+// types are ignored, and error frames returned as errors, as the server
+// closes the stream after them. Messages that fail to decode become events
+// carrying the error, so that one bad message does not stop the stream.
+func decodeFrame(msg []byte) (*frame, error) {
+	r := cbg.NewCborReader(bytes.NewReader(msg))
+	hdr, err := readCBORMap(r)
+	if err != nil {
+		return &frame{evts: []*Event{badFrameEvent("", "", 0, "", fmt.Errorf("invalid frame header: %w", err))}}, nil
+	}
+	op, _ := hdr["op"].(int64)
+	if op == frameOpError {
+		ef, err := readCBORMap(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid firehose error frame: %w", err)
+		}
+		return nil, fmt.Errorf("firehose error %v: %v", ef["error"], ef["message"])
+	}
+	if op != frameOpMessage {
+		return nil, nil
+	}
+
+	msgType, _ := hdr["t"].(string)
+	switch msgType {
+	case "#commit":
+		var m comatproto.SyncSubscribeRepos_Commit
+		if err := m.UnmarshalCBOR(r); err != nil {
+			// fields read before the error, such as seq, are kept
+			return &frame{evts: []*Event{badFrameEvent(m.Repo, models.EventKindCommit, m.Seq, msgType, err)}}, nil
+		}
+		return &frame{evts: commitEvents(&m), commit: &m}, nil
+	case "#sync":
+		var m comatproto.SyncSubscribeRepos_Sync
+		if err := m.UnmarshalCBOR(r); err != nil {
+			return &frame{evts: []*Event{badFrameEvent(m.Did, EventKindSync, m.Seq, msgType, err)}}, nil
+		}
+		info := &FirehoseInfo{Seq: m.Seq, Type: msgType, Time: m.Time, Rev: m.Rev, BlocksSize: len(m.Blocks)}
+		blocks, _, err := readBlocks(m.Blocks)
+		if err != nil {
+			info.Error = err.Error()
+		}
+		info.Blocks = blocks
+		evt := firehoseEvent(m.Did, EventKindSync, info)
+		return &frame{evts: []*Event{evt}, sync: &m}, nil
+	case "#identity":
+		var m comatproto.SyncSubscribeRepos_Identity
+		if err := m.UnmarshalCBOR(r); err != nil {
+			return &frame{evts: []*Event{badFrameEvent(m.Did, models.EventKindIdentity, m.Seq, msgType, err)}}, nil
+		}
+		evt := firehoseEvent(m.Did, models.EventKindIdentity, &FirehoseInfo{Seq: m.Seq, Type: msgType, Time: m.Time})
+		evt.Identity = &m
+		return &frame{evts: []*Event{evt}, identity: m.Did}, nil
+	case "#account":
+		var m comatproto.SyncSubscribeRepos_Account
+		if err := m.UnmarshalCBOR(r); err != nil {
+			return &frame{evts: []*Event{badFrameEvent(m.Did, models.EventKindAccount, m.Seq, msgType, err)}}, nil
+		}
+		evt := firehoseEvent(m.Did, models.EventKindAccount, &FirehoseInfo{Seq: m.Seq, Type: msgType, Time: m.Time})
+		evt.Account = &m
+		return &frame{evts: []*Event{evt}}, nil
+	}
+	return nil, nil
+}
+
+// badFrameEvent stands for a message that could not be decoded, with what
+// was read of it. The kind is empty when not even the header decoded.
+func badFrameEvent(did, kind string, seq int64, msgType string, err error) *Event {
+	if kind == "" {
+		kind = EventKindError
+	}
+	return firehoseEvent(did, kind, &FirehoseInfo{Seq: seq, Type: msgType, Error: "undecodable message: " + err.Error()})
+}
+
+// readCBORMap reads a map of string and integer values, as used by frame
+// headers and error frames.
+func readCBORMap(r *cbg.CborReader) (map[string]any, error) {
+	maj, n, err := r.ReadHeader()
+	if err != nil {
+		return nil, err
+	}
+	if maj != cbg.MajMap {
+		return nil, fmt.Errorf("expected a map, got major type %d", maj)
+	}
+	out := make(map[string]any, n)
+	for range n {
+		key, err := cbg.ReadString(r)
+		if err != nil {
+			return nil, err
+		}
+		maj, v, err := r.ReadHeader()
+		if err != nil {
+			return nil, err
+		}
+		switch maj {
+		case cbg.MajUnsignedInt:
+			out[key] = int64(v)
+		case cbg.MajNegativeInt:
+			out[key] = -1 - int64(v)
+		case cbg.MajTextString:
+			buf := make([]byte, v)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, err
+			}
+			out[key] = string(buf)
+		default:
+			return nil, fmt.Errorf("unexpected major type %d for %q", maj, key)
+		}
+	}
+	return out, nil
+}
+
+func firehoseEvent(did, kind string, info *FirehoseInfo) *Event {
+	us := time.Now().UnixMicro()
+	if t, err := time.Parse(time.RFC3339, info.Time); err == nil {
+		us = t.UnixMicro()
+	}
+	return &Event{
+		Event:    models.Event{Did: did, Kind: kind, TimeUS: us},
+		Firehose: info,
+	}
+}
+
+// commitEvents returns an event per operation of a commit, with records
+// decoded from the blocks of its CAR slice.
+// Records that fail to decode are left out, noted in the error of the
+// commit.
+func commitEvents(m *comatproto.SyncSubscribeRepos_Commit) []*Event {
+	var errs []string
+	blocks, data, err := readBlocks(m.Blocks)
+	if err != nil {
+		errs = append(errs, err.Error())
+	}
+	info := &FirehoseInfo{
+		Seq:        m.Seq,
+		Type:       "#commit",
+		Time:       m.Time,
+		Rev:        m.Rev,
+		Commit:     m.Commit.String(),
+		Blocks:     blocks,
+		BlocksSize: len(m.Blocks),
+		TooBig:     m.TooBig,
+		Rebase:     m.Rebase,
+	}
+	if m.Since != nil {
+		info.Since = *m.Since
+	}
+	if m.PrevData != nil {
+		info.PrevData = m.PrevData.String()
+	}
+	for _, b := range m.Blobs {
+		info.Blobs = append(info.Blobs, b.String())
+	}
+	for _, op := range m.Ops {
+		fop := FirehoseOp{Action: op.Action, Path: op.Path}
+		if op.Cid != nil {
+			fop.CID = op.Cid.String()
+		}
+		if op.Prev != nil {
+			fop.Prev = op.Prev.String()
+		}
+		info.Ops = append(info.Ops, fop)
+	}
+	if len(m.Ops) == 0 {
+		info.Error = strings.Join(errs, "; ")
+		return []*Event{firehoseEvent(m.Repo, models.EventKindCommit, info)}
+	}
+
+	evts := make([]*Event, 0, len(m.Ops))
+	for _, op := range m.Ops {
+		evt := firehoseEvent(m.Repo, models.EventKindCommit, info)
+		collection, rkey, _ := strings.Cut(op.Path, "/")
+		evt.Commit = &models.Commit{
+			Rev:        m.Rev,
+			Operation:  op.Action,
+			Collection: collection,
+			RKey:       rkey,
+		}
+		if op.Cid != nil {
+			evt.Commit.CID = op.Cid.String()
+			if block, ok := data[cid.Cid(*op.Cid)]; ok {
+				rec, err := recordJSON(block)
+				if err != nil {
+					errs = append(errs, fmt.Sprintf("invalid record %s: %v", op.Path, err))
+				}
+				evt.Commit.Record = rec
+			}
+		}
+		evts = append(evts, evt)
+	}
+	info.Error = strings.Join(errs, "; ")
+	return evts
+}
+
+// readBlocks reads a CAR slice, returning the CIDs of its blocks in order
+// and their data.
+func readBlocks(slice []byte) ([]string, map[cid.Cid][]byte, error) {
+	if len(slice) == 0 {
+		return nil, nil, nil
+	}
+	cr, err := car.NewCarReader(bytes.NewReader(slice))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid CAR slice: %w", err)
+	}
+	var cids []string
+	data := map[cid.Cid][]byte{}
+	for {
+		blk, err := cr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CAR slice: %w", err)
+		}
+		cids = append(cids, blk.Cid().String())
+		data[blk.Cid()] = blk.RawData()
+	}
+	return cids, data, nil
+}
+
+// recordJSON converts a DAG-CBOR record to its JSON form.
+func recordJSON(block []byte) (json.RawMessage, error) {
+	obj, err := atdata.UnmarshalCBOR(block)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
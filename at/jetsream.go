@@ -2,71 +2,386 @@ package at
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 
-	jetstream "github.com/bluesky-social/jetstream/pkg/client"
-	"github.com/bluesky-social/jetstream/pkg/client/schedulers/sequential"
+	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/bluesky-social/jetstream/pkg/models"
+	"github.com/gorilla/websocket"
+	"github.com/klauspost/compress/zstd"
 )
 
+const DefaultJetStreamURL = "wss://jetstream1.us-west.bsky.network/subscribe"
+
+// Limits JetStream puts on the filters of a subscriber.
+const (
+	maxWantedCollections = 100
+	maxWantedDIDs        = 10_000
+)
+
+// Event is a JetStream event, or a firehose message in the same shape with
+// the details JetStream leaves out.
+type Event struct {
+	models.Event
+	Firehose *FirehoseInfo `json:"firehose,omitempty"`
+}
+
+// Cursor returns the position to resume the stream after the event: the
+// sequence number of a firehose message, or the time of a JetStream event.
+// It is 0 for firehose messages too broken to tell their sequence number.
+func (e *Event) Cursor() int64 {
+	if e.Firehose != nil {
+		return e.Firehose.Seq
+	}
+	return e.TimeUS
+}
+
 type JetStreamClient struct {
-	sched jetstream.Scheduler
-	log   *slog.Logger
-	out   chan *models.Event
-	err	 chan error
+	log *slog.Logger
+	// block waits for room in the stream instead of dropping events
+	block    atomic.Bool
+	dropped  atomic.Int64
+	recorder atomic.Pointer[Recorder]
+
+	// compress requests zstd messages, decoded with JetStream's dictionary
+	compress atomic.Bool
+	decoder  *zstd.Decoder
+	// bytes read from the connection and after decoding
+	received atomic.Int64
+	decoded  atomic.Int64
+
+	// filters applied to firehose events
+	filter atomic.Pointer[firehoseFilter]
+	// verify checks firehose commits, reporting signatures on verified
+	verify   atomic.Bool
+	verifier *verifier
+	verified chan SignatureResult
+
+	mu  sync.Mutex
+	con *websocket.Conn
+	// url is the JetStream subscribe endpoint
+	url string
+	// subscribeRepos endpoint read instead of JetStream, if set
+	firehose string
 }
 
 func NewJetstreamClient() *JetStreamClient {
-	log := slog.Default()
 	c := &JetStreamClient{
-		log:   log,
-		out:   make(chan *models.Event, 512),
-		err: make(chan error, 1),
+		log:      slog.Default(),
+		verifier: newVerifier(),
+		verified: make(chan SignatureResult, 512),
+		url:      DefaultJetStreamURL,
+	}
+	c.verify.Store(true)
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderDicts(models.ZSTDDictionary))
+	if err != nil {
+		c.log.Error("failed to create zstd decoder, compression disabled", "error", err)
+		return c
 	}
-	scheduler := sequential.NewScheduler("jetstream", slog.Default(), c.handleEvent)
-	c.sched = scheduler
+	c.decoder = dec
+	c.compress.Store(true)
 	return c
+}
 
+// Stream is a single run of the client: the events it read, and how it
+// ended. Each run has its own channels, so events and errors of a stopped
+// run never reach the next one.
+type Stream struct {
+	out       chan *Event
+	err       chan error
+	connected chan struct{}
 }
 
-func (c *JetStreamClient) Start(ctx context.Context, cxs, dids []string, cursor *int64) {
-	config := &jetstream.ClientConfig{
-		WebsocketURL:      "wss://jetstream1.us-west.bsky.network/subscribe",
-		Compress:          false,
-		WantedDids:        dids,
-		WantedCollections: cxs,
-		ExtraHeaders: map[string]string{
-			"User-Agent": "attie/0.0.1",
-		},
+func newStream() *Stream {
+	return &Stream{
+		out:       make(chan *Event, 512),
+		err:       make(chan error, 1),
+		connected: make(chan struct{}, 1),
+	}
+}
+
+// Out returns the events of the run.
+func (s *Stream) Out() <-chan *Event {
+	return s.out
+}
+
+// Err reports the outcome of the run once it has ended. Events read before
+// are still in Out.
+func (s *Stream) Err() <-chan error {
+	return s.err
+}
+
+// Connected is signalled once the connection of the run is open.
+func (s *Stream) Connected() <-chan struct{} {
+	return s.connected
+}
+
+// Start connects in the background and reads events until ctx is done or
+// the connection fails, reporting the outcome on the Err of the returned
+// stream.
+func (c *JetStreamClient) Start(ctx context.Context, cxs, dids []string, cursor *int64) *Stream {
+	s := newStream()
+	u := c.Firehose()
+	go func() {
+		if u != "" {
+			s.err <- c.readFirehose(ctx, s, u, cxs, dids, cursor)
+			return
+		}
+		s.err <- c.connectAndRead(ctx, s, cxs, dids, cursor)
+	}()
+	return s
+}
+
+func (c *JetStreamClient) connectAndRead(ctx context.Context, s *Stream, cxs, dids []string, cursor *int64) error {
+	params := url.Values{}
+	for _, cx := range cxs {
+		params.Add("wantedCollections", cx)
+	}
+	for _, did := range dids {
+		params.Add("wantedDids", did)
+	}
+	if cursor != nil {
+		params.Set("cursor", fmt.Sprintf("%d", *cursor))
+	}
+	c.mu.Lock()
+	u := c.url
+	c.mu.Unlock()
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	header := http.Header{"User-Agent": {"attie/0.0.1"}}
+	compress := c.Compressed()
+	if compress {
+		header.Set("Socket-Encoding", "zstd")
 	}
-	jc, err := jetstream.NewClient(config, c.log, c.sched)
+	c.log.Info("connecting to jetstream", "url", u, "compress", compress)
+	con, _, err := websocket.DefaultDialer.DialContext(ctx, u, header)
 	if err != nil {
-		c.err <- err
-		return 
+		return fmt.Errorf("failed to connect to jetstream: %w", err)
+	}
+	c.mu.Lock()
+	c.con = con
+	c.mu.Unlock()
+	select {
+	case s.connected <- struct{}{}:
+	default:
+	}
+	defer func() {
+		c.mu.Lock()
+		// a newer run may have connected already
+		if c.con == con {
+			c.con = nil
+		}
+		c.mu.Unlock()
+	}()
+
+	// unblock the read when stopped
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			con.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		typ, msg, err := con.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			con.Close()
+			return fmt.Errorf("failed to read from jetstream: %w", err)
+		}
+		c.received.Add(int64(len(msg)))
+		if typ == websocket.BinaryMessage && compress {
+			msg, err = c.decoder.DecodeAll(msg, nil)
+			if err != nil {
+				c.log.Error("failed to decode event", "error", err)
+				continue
+			}
+		}
+		c.decoded.Add(int64(len(msg)))
+		if rec := c.recorder.Load(); rec != nil {
+			if err := rec.Write(msg); err != nil {
+				c.log.Error("failed to record event", "error", err)
+			}
+		}
+		var evt Event
+		if err := json.Unmarshal(msg, &evt); err != nil {
+			c.log.Error("failed to unmarshal event", "error", err)
+			continue
+		}
+		if err := c.handleEvent(ctx, s, &evt); err != nil {
+			return err
+		}
+	}
+}
+
+// SetURL makes the next connections subscribe to the JetStream instance at
+// u, DefaultJetStreamURL by default.
+func (c *JetStreamClient) SetURL(u string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if u == "" {
+		u = DefaultJetStreamURL
 	}
+	c.url = u
+}
+
+// UpdateOptions replaces the filters of the open connection, keeping its
+// position in the stream.
+func (c *JetStreamClient) UpdateOptions(cxs, dids []string) error {
+	if err := ValidateFilters(cxs, dids); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.firehose != "" {
+		c.filter.Store(&firehoseFilter{collections: cxs, dids: dids})
+		return nil
+	}
+	if c.con == nil {
+		return errors.New("jetstream is not connected")
+	}
+	msg := map[string]any{
+		"type": "options_update",
+		"payload": map[string]any{
+			"wantedCollections":   append([]string{}, cxs...),
+			"wantedDids":          append([]string{}, dids...),
+			"maxMessageSizeBytes": 0,
+		},
+	}
+	if err := c.con.WriteJSON(msg); err != nil {
+		return fmt.Errorf("failed to update jetstream options: %w", err)
+	}
+	c.log.Info("updated jetstream options", "collections", cxs, "dids", dids)
+	return nil
+}
+
+// ValidateFilters checks filters the way JetStream does, since an invalid
+// options update closes the connection. Collections may be NSIDs or NSID
+// prefixes ending in ".*".
+func ValidateFilters(cxs, dids []string) error {
+	if len(cxs) > maxWantedCollections {
+		return fmt.Errorf("at most %d collections can be filtered", maxWantedCollections)
+	}
+	if len(dids) > maxWantedDIDs {
+		return fmt.Errorf("at most %d DIDs can be filtered", maxWantedDIDs)
+	}
+	for _, cx := range cxs {
+		nsid := cx
+		if prefix, ok := strings.CutSuffix(cx, ".*"); ok {
+			// any name completes a valid prefix
+			nsid = prefix + ".any"
+		}
+		if _, err := syntax.ParseNSID(nsid); err != nil {
+			return fmt.Errorf("invalid collection %q", cx)
+		}
+	}
+	for _, did := range dids {
+		if _, err := syntax.ParseDID(did); err != nil {
+			return fmt.Errorf("invalid DID %q", did)
+		}
+	}
+	return nil
+}
+
+// SetRecorder records raw messages from the connection to r, or stops
+// recording when r is nil. Firehose frames are recorded when they have an
+// event passing the filters.
+func (c *JetStreamClient) SetRecorder(r *Recorder) {
+	c.recorder.Store(r)
+}
 
-	c.err <- jc.ConnectAndRead(ctx, cursor)
+// SetCompression chooses whether the next connection requests zstd
+// compressed messages. It has no effect without a decoder.
+func (c *JetStreamClient) SetCompression(compress bool) {
+	c.compress.Store(compress && c.decoder != nil)
 }
 
-func (c *JetStreamClient) Out() <-chan *models.Event {
-	return c.out
+func (c *JetStreamClient) Compressed() bool {
+	return c.compress.Load()
 }
-func (c *JetStreamClient) Err() <-chan error {
-	return c.err
+
+// Bytes returns the bytes received from the connection and their size
+// after decoding, since the last ResetBytes.
+func (c *JetStreamClient) Bytes() (received, decoded int64) {
+	return c.received.Load(), c.decoded.Load()
+}
+
+func (c *JetStreamClient) ResetBytes() {
+	c.received.Store(0)
+	c.decoded.Store(0)
+}
+
+// SetVerify chooses whether firehose commits are verified.
+func (c *JetStreamClient) SetVerify(verify bool) {
+	c.verify.Store(verify)
+}
+
+func (c *JetStreamClient) Verifying() bool {
+	return c.verify.Load()
+}
+
+// Verified reports the signature checks of verifications attached to
+// events, to be applied by the receiver.
+func (c *JetStreamClient) Verified() <-chan SignatureResult {
+	return c.verified
+}
+
+// Verifications returns the number of commits found valid and invalid, and
+// of those without problems whose signature was not checked, since the last
+// ResetVerifications.
+func (c *JetStreamClient) Verifications() (valid, invalid, unverified int64) {
+	return c.verifier.valid.Load(), c.verifier.invalid.Load(), c.verifier.unverified.Load()
+}
+
+func (c *JetStreamClient) ResetVerifications() {
+	c.verifier.resetCounts()
+}
+
+// SetBlocking chooses between blocking the read loop and dropping events
+// while the consumer falls behind.
+func (c *JetStreamClient) SetBlocking(block bool) {
+	c.block.Store(block)
 }
 
-func (c *JetStreamClient) handleEvent(ctx context.Context, ev *models.Event) error {
+// Dropped returns the number of events dropped since the last ResetDropped.
+func (c *JetStreamClient) Dropped() int64 {
+	return c.dropped.Load()
+}
+
+func (c *JetStreamClient) ResetDropped() {
+	c.dropped.Store(0)
+}
+
+func (c *JetStreamClient) handleEvent(ctx context.Context, s *Stream, ev *Event) error {
 	slog.Info("Received event", "did", ev.Did, "kind", ev.Kind)
-	if ev.Commit == nil {
-		slog.Info("skipping non commit event ", "did", ev.Did, "kind", ev.Kind)
+	if c.block.Load() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case s.out <- ev:
+		}
 		return nil
 	}
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case c.out <- ev:
-		return nil
+	case s.out <- ev:
 	default:
+		c.dropped.Add(1)
 		slog.Warn("dropped event", "did", ev.Did, "kind", ev.Kind)
 	}
 	return nil
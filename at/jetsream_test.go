@@ -0,0 +1,161 @@
+package at
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeJetStream serves each connection with the next handler, recording
+// the query it was opened with.
+type fakeJetStream struct {
+	*httptest.Server
+	queries chan url.Values
+}
+
+func newFakeJetStream(t *testing.T, handlers ...func(*websocket.Conn)) *fakeJetStream {
+	t.Helper()
+	f := &fakeJetStream{queries: make(chan url.Values, len(handlers))}
+	conns := make(chan func(*websocket.Conn), len(handlers))
+	for _, h := range handlers {
+		conns <- h
+	}
+	var upgrader websocket.Upgrader
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var h func(*websocket.Conn)
+		select {
+		case h = <-conns:
+		default:
+			http.Error(w, "no more connections expected", http.StatusServiceUnavailable)
+			return
+		}
+		con, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer con.Close()
+		f.queries <- r.URL.Query()
+		h(con)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeJetStream) client() *JetStreamClient {
+	c := NewJetstreamClient()
+	c.url = "ws" + strings.TrimPrefix(f.URL, "http")
+	c.SetCompression(false)
+	return c
+}
+
+func (f *fakeJetStream) query(t *testing.T) url.Values {
+	t.Helper()
+	select {
+	case q := <-f.queries:
+		return q
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection")
+		return nil
+	}
+}
+
+// sendEvents writes commit events at the given times and closes the
+// connection.
+func sendEvents(times ...int64) func(*websocket.Conn) {
+	return func(con *websocket.Conn) {
+		for _, us := range times {
+			evt := fmt.Sprintf(`{"did":"did:plc:abc","time_us":%d,"kind":"commit"}`, us)
+			if err := con.WriteMessage(websocket.TextMessage, []byte(evt)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func receive(t *testing.T, s *Stream) *Event {
+	t.Helper()
+	select {
+	case evt := <-s.Out():
+		return evt
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return nil
+}
+
+func TestJetStreamUpdateOptions(t *testing.T) {
+	updates := make(chan []byte, 1)
+	f := newFakeJetStream(t, func(con *websocket.Conn) {
+		_, msg, err := con.ReadMessage()
+		if err != nil {
+			return
+		}
+		updates <- msg
+		sendEvents(30)(con)
+		// hold the connection open until the client stops
+		con.ReadMessage()
+	})
+	c := f.client()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := c.Start(ctx, nil, nil, nil)
+	f.query(t)
+	select {
+	case <-s.Connected():
+	case <-time.After(5 * time.Second):
+		t.Fatal("not connected")
+	}
+
+	if err := c.UpdateOptions([]string{"not a collection"}, nil); err == nil {
+		t.Error("invalid collection was accepted")
+	}
+	if err := c.UpdateOptions([]string{"app.bsky.graph.*"}, []string{"did:plc:abc"}); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Type    string `json:"type"`
+		Payload struct {
+			WantedCollections []string `json:"wantedCollections"`
+			WantedDids        []string `json:"wantedDids"`
+		} `json:"payload"`
+	}
+	select {
+	case msg := <-updates:
+		if err := json.Unmarshal(msg, &got); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no options update")
+	}
+	if got.Type != "options_update" {
+		t.Errorf("type = %q, want options_update", got.Type)
+	}
+	if len(got.Payload.WantedCollections) != 1 || got.Payload.WantedCollections[0] != "app.bsky.graph.*" {
+		t.Errorf("wantedCollections = %v", got.Payload.WantedCollections)
+	}
+	if len(got.Payload.WantedDids) != 1 || got.Payload.WantedDids[0] != "did:plc:abc" {
+		t.Errorf("wantedDids = %v", got.Payload.WantedDids)
+	}
+	// the connection stays open
+	if evt := receive(t, s); evt.Cursor() != 30 {
+		t.Errorf("got event at %d, want 30", evt.Cursor())
+	}
+
+	cancel()
+	select {
+	case err := <-s.Err():
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("stopped stream reported %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not stop")
+	}
+}
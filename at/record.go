@@ -0,0 +1,188 @@
+package at
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ErrReplayFinished is reported on Err once a replayed file is exhausted.
+var ErrReplayFinished = errors.New("replay finished")
+
+// Recorder writes raw JetStream messages as NDJSON, zstd compressed when the
+// path ends in ".zst". Binary firehose frames are written as a line holding
+// the frame, see WriteFrame.
+type Recorder struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	zw   *zstd.Encoder
+	w    *bufio.Writer
+	n    int
+}
+
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	r := &Recorder{path: path, f: f}
+	var w io.Writer = f
+	if strings.HasSuffix(path, ".zst") {
+		r.zw, err = zstd.NewWriter(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		w = r.zw
+	}
+	r.w = bufio.NewWriter(w)
+	return r, nil
+}
+
+// Write appends a message as a line.
+func (r *Recorder) Write(msg []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(msg); err != nil {
+		return err
+	}
+	if err := r.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	r.n++
+	return nil
+}
+
+// recordedFrame is the line of a firehose frame in a recording.
+type recordedFrame struct {
+	Frame []byte `json:"frame"`
+}
+
+var recordedFramePrefix = []byte(`{"frame":`)
+
+// WriteFrame appends a firehose frame as received, base64 encoded in a
+// JSON object, so that it is decoded again when replayed.
+func (r *Recorder) WriteFrame(frame []byte) error {
+	line, err := json.Marshal(recordedFrame{Frame: frame})
+	if err != nil {
+		return err
+	}
+	return r.Write(line)
+}
+
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Count returns the number of messages written.
+func (r *Recorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.n
+}
+
+// Close flushes the recording and closes the file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.w.Flush()
+	if r.zw != nil {
+		err = errors.Join(err, r.zw.Close())
+	}
+	return errors.Join(err, r.f.Close())
+}
+
+// Replay reads a recording made by Recorder in the background and emits its
+// events on the returned stream, spaced by their original timestamps divided
+// by speed. A speed of 0 replays as fast as possible. The outcome is reported
+// on Err, ErrReplayFinished once the file is exhausted.
+func (c *JetStreamClient) Replay(ctx context.Context, path string, speed float64) *Stream {
+	s := newStream()
+	go func() { s.err <- c.replay(ctx, s, path, speed) }()
+	return s
+}
+
+func (c *JetStreamClient) replay(ctx context.Context, s *Stream, path string, speed float64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".zst") {
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to read zstd recording: %w", err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	br := bufio.NewReader(r)
+	var last int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			evts, err := recordedEvents(line)
+			if err != nil {
+				return fmt.Errorf("invalid event in recording: %w", err)
+			}
+			for _, evt := range evts {
+				if last != 0 && speed > 0 && evt.TimeUS > last {
+					wait := time.Duration(float64(evt.TimeUS-last) * float64(time.Microsecond) / speed)
+					select {
+					case <-ctx.Done():
+						return ctx.Err()
+					case <-time.After(wait):
+					}
+				}
+				last = evt.TimeUS
+				// a recording can always wait for the consumer
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case s.out <- evt:
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return ErrReplayFinished
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read recording: %w", err)
+		}
+	}
+}
+
+// recordedEvents decodes a line of a recording: a JetStream event, or a
+// firehose frame, which yields an event per operation. Firehose events are
+// replayed unverified.
+func recordedEvents(line []byte) ([]*Event, error) {
+	if bytes.HasPrefix(line, recordedFramePrefix) {
+		var rf recordedFrame
+		if err := json.Unmarshal(line, &rf); err != nil {
+			return nil, err
+		}
+		f, err := decodeFrame(rf.Frame)
+		if err != nil || f == nil {
+			return nil, err
+		}
+		return f.evts, nil
+	}
+	var evt Event
+	if err := json.Unmarshal(line, &evt); err != nil {
+		return nil, err
+	}
+	return []*Event{&evt}, nil
+}
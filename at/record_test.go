@@ -0,0 +1,55 @@
+package at
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/jetstream/pkg/models"
+)
+
+func TestReplayRecordedFirehoseFrame(t *testing.T) {
+	handle := "alice.test"
+	var frame bytes.Buffer
+	// header {"op": 1, "t": "#identity"}
+	frame.Write([]byte{0xa2, 0x62, 'o', 'p', 0x01, 0x61, 't', 0x69})
+	frame.WriteString("#identity")
+	m := comatproto.SyncSubscribeRepos_Identity{Did: "did:plc:abc", Handle: &handle, Seq: 42, Time: "2024-09-05T14:30:00Z"}
+	if err := m.MarshalCBOR(&frame); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "firehose.jsonl.zst")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Write([]byte(`{"did":"did:plc:def","time_us":1725546600000000,"kind":"commit"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.WriteFrame(frame.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewJetstreamClient()
+	s := c.Replay(context.Background(), path, 0)
+	if err := <-s.Err(); !errors.Is(err, ErrReplayFinished) {
+		t.Fatalf("replay ended with %v", err)
+	}
+	if evt := <-s.Out(); evt.Did != "did:plc:def" || evt.Firehose != nil {
+		t.Errorf("first event = %+v, want the JetStream event", evt)
+	}
+	evt := <-s.Out()
+	if evt.Firehose == nil || evt.Firehose.Seq != 42 || evt.Firehose.Verify != nil {
+		t.Fatalf("second event = %+v, want the unverified firehose message", evt.Firehose)
+	}
+	if evt.Kind != models.EventKindIdentity || evt.Identity == nil || *evt.Identity.Handle != handle {
+		t.Errorf("replayed identity = %+v", evt.Identity)
+	}
+}
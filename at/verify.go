@@ -0,0 +1,318 @@
+package at
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	comatproto "github.com/bluesky-social/indigo/api/atproto"
+	"github.com/bluesky-social/indigo/atproto/identity"
+	"github.com/bluesky-social/indigo/atproto/repo"
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/ipfs/go-cid"
+)
+
+// Verification states of a firehose commit.
+const (
+	VerifyPending = "pending"
+	VerifyValid   = "valid"
+	VerifyInvalid = "invalid"
+	// VerifyUnverified is the status of commits without problems whose
+	// signature was skipped.
+	VerifyUnverified = "unverified"
+	// VerifySkipped is the signature state of commits whose DID did not
+	// resolve, or received while too many signatures were being checked.
+	VerifySkipped = "skipped"
+)
+
+const (
+	// signatures checked at once, each may need to resolve a DID
+	maxSignatureChecks = 16
+	// repos whose last commit is remembered to check the next one against
+	maxVerifiedRepos = 100_000
+)
+
+// Verification is the outcome of checking a firehose commit against the
+// previous commit of its repo and the signing key of its DID.
+type Verification struct {
+	Status    string   `json:"status"`
+	Signature string   `json:"signature"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+func (v *Verification) update() {
+	switch {
+	case len(v.Errors) > 0:
+		v.Status = VerifyInvalid
+	case v.Signature == VerifyPending:
+		v.Status = VerifyPending
+	case v.Signature == VerifySkipped:
+		v.Status = VerifyUnverified
+	default:
+		v.Status = VerifyValid
+	}
+}
+
+// SignatureResult completes the verification of a message once its
+// signature is checked. It is applied by the receiver of Verified, which
+// owns the events.
+type SignatureResult struct {
+	// Info is the message, whose Verify the result completes
+	Info *FirehoseInfo
+	Err  error
+	// Unchecked is why the signature could not be checked, such as the DID
+	// failing to resolve
+	Unchecked error
+}
+
+// Apply records the signature check on the message. The verification is
+// replaced rather than changed, as events may be read outside the receiver,
+// e.g. while exported.
+func (r SignatureResult) Apply() {
+	ver := *r.Info.Verify
+	ver.Errors = slices.Clone(ver.Errors)
+	ver.Signature = VerifyValid
+	switch {
+	case r.Unchecked != nil:
+		ver.Signature = VerifySkipped
+	case r.Err != nil:
+		ver.Signature = VerifyInvalid
+		ver.Errors = append(ver.Errors, "signature: "+r.Err.Error())
+	}
+	ver.update()
+	r.Info.Verify = &ver
+}
+
+type repoState struct {
+	rev string
+	// data is undefined when the commit could not be read
+	data cid.Cid
+}
+
+// verifier checks firehose commits inductively: each commit must follow the
+// previous one of its repo, and its operations must transform the MST of
+// the previous commit into the one of the commit.
+type verifier struct {
+	dir identity.Directory
+	sem chan struct{}
+
+	mu      sync.Mutex
+	lastSeq int64
+	repos   map[string]repoState
+
+	valid      atomic.Int64
+	invalid    atomic.Int64
+	unverified atomic.Int64
+}
+
+func newVerifier() *verifier {
+	return &verifier{
+		dir:   identity.DefaultDirectory(),
+		sem:   make(chan struct{}, maxSignatureChecks),
+		repos: map[string]repoState{},
+	}
+}
+
+func (v *verifier) reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.lastSeq = 0
+	v.repos = map[string]repoState{}
+	v.resetCounts()
+}
+
+func (v *verifier) resetCounts() {
+	v.valid.Store(0)
+	v.invalid.Store(0)
+	v.unverified.Store(0)
+}
+
+// rewind forgets the chains unless the stream continues from the last
+// message seen, as commits in between would be missed.
+func (v *verifier) rewind(cursor *int64) {
+	v.mu.Lock()
+	last := v.lastSeq
+	v.mu.Unlock()
+	if cursor == nil || *cursor != last {
+		v.reset()
+	}
+}
+
+func (v *verifier) checkSeq(seq int64) []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	var errs []string
+	if v.lastSeq > 0 && seq <= v.lastSeq {
+		errs = append(errs, fmt.Sprintf("out of order: seq %d after %d", seq, v.lastSeq))
+	}
+	v.lastSeq = max(v.lastSeq, seq)
+	return errs
+}
+
+// setRepo remembers the last commit of a repo.
+func (v *verifier) setRepo(did string, state repoState) (repoState, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	prev, ok := v.repos[did]
+	if !ok && len(v.repos) >= maxVerifiedRepos {
+		for k := range v.repos {
+			delete(v.repos, k)
+			break
+		}
+	}
+	v.repos[did] = state
+	return prev, ok
+}
+
+// dropRepo forgets the last commit of a repo, its next commit starts a new
+// chain.
+func (v *verifier) dropRepo(did string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.repos, did)
+}
+
+// checkCommit checks a commit without the network: its order in the stream
+// and in its repo, that it follows the previous commit of its repo, and
+// with full set, the MST proof of its operations. The chain is tracked for
+// every commit so that it stays intact for repos that are only partly
+// shown.
+func (v *verifier) checkCommit(ctx context.Context, m *comatproto.SyncSubscribeRepos_Commit, full bool) []string {
+	errs := v.checkSeq(m.Seq)
+	// the rev is still followed when the commit can't be read, so that the
+	// next commit is checked against this one rather than an older one
+	state := repoState{rev: m.Rev}
+	commit, root, err := repo.LoadCommitFromCAR(ctx, bytes.NewReader(m.Blocks))
+	if err != nil {
+		errs = append(errs, "invalid CAR slice: "+err.Error())
+	} else {
+		if *root != cid.Cid(m.Commit) {
+			errs = append(errs, "commit CID does not match the root of the CAR slice")
+		}
+		state.data = commit.Data
+	}
+
+	prev, ok := v.setRepo(m.Repo, state)
+	if ok {
+		// TIDs sort lexically
+		if m.Rev <= prev.rev {
+			errs = append(errs, fmt.Sprintf("out of order: rev %s not after %s", m.Rev, prev.rev))
+			// keep following from the newer commit
+			v.setRepo(m.Repo, prev)
+		}
+		if m.Since == nil || *m.Since != prev.rev {
+			since := "null"
+			if m.Since != nil {
+				since = *m.Since
+			}
+			errs = append(errs, fmt.Sprintf("since %s does not match the previous rev %s", since, prev.rev))
+		}
+		if prev.data.Defined() && (m.PrevData == nil || cid.Cid(*m.PrevData) != prev.data) {
+			errs = append(errs, "prevData does not match the data of the previous commit")
+		}
+	}
+
+	if full && commit != nil {
+		if _, err := repo.VerifyCommitMessage(ctx, m); err != nil {
+			errs = append(errs, "MST proof: "+err.Error())
+		}
+	}
+	return errs
+}
+
+// resync restarts the chain of a repo from a #sync message.
+func (v *verifier) resync(ctx context.Context, m *comatproto.SyncSubscribeRepos_Sync) []string {
+	errs := v.checkSeq(m.Seq)
+	commit, _, err := repo.LoadCommitFromCAR(ctx, bytes.NewReader(m.Blocks))
+	if err != nil {
+		// the chain restarts with the next commit
+		v.dropRepo(m.Did)
+		return append(errs, "invalid CAR slice: "+err.Error())
+	}
+	v.setRepo(m.Did, repoState{rev: commit.Rev, data: commit.Data})
+	return errs
+}
+
+// verify starts checking a frame's commit or sync message. With info set,
+// the message is checked fully and its pending verification set on info;
+// the signature is then checked in the background and reported on out.
+func (v *verifier) verify(ctx context.Context, f *frame, info *FirehoseInfo, out chan<- SignatureResult) {
+	full := info != nil
+	var errs []string
+	var did string
+	var blocks []byte
+	switch {
+	case f == nil:
+		return
+	case f.commit != nil:
+		errs = v.checkCommit(ctx, f.commit, full)
+		did, blocks = f.commit.Repo, f.commit.Blocks
+	case f.sync != nil:
+		errs = v.resync(ctx, f.sync)
+		did, blocks = f.sync.Did, f.sync.Blocks
+	case f.identity != "":
+		// the signing key may have changed
+		if did, err := syntax.ParseDID(f.identity); err == nil {
+			v.dir.Purge(ctx, did.AtIdentifier())
+		}
+		return
+	default:
+		return
+	}
+	if !full {
+		return
+	}
+
+	ver := &Verification{Signature: VerifyPending, Errors: errs}
+	select {
+	case v.sem <- struct{}{}:
+	default:
+		ver.Signature = VerifySkipped
+	}
+	ver.update()
+	// set before the check starts, so that its result finds it
+	info.Verify = ver
+	if ver.Signature == VerifySkipped {
+		v.count(ver)
+		return
+	}
+	go func() {
+		res := SignatureResult{Info: info}
+		// resolved first so that lookup failures are not taken for bad
+		// signatures, the check then uses the cached identity
+		if parsed, err := syntax.ParseDID(did); err != nil {
+			res.Err = err
+		} else if _, err := v.dir.LookupDID(ctx, parsed); err != nil {
+			res.Unchecked = err
+		} else {
+			_, res.Err = repo.VerifyCommitSignatureFromCar(ctx, v.dir, blocks)
+		}
+		<-v.sem
+		switch {
+		case len(errs) > 0 || res.Err != nil:
+			v.invalid.Add(1)
+		case res.Unchecked != nil:
+			v.unverified.Add(1)
+		default:
+			v.valid.Add(1)
+		}
+		select {
+		case out <- res:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (v *verifier) count(ver *Verification) {
+	switch ver.Status {
+	case VerifyInvalid:
+		v.invalid.Add(1)
+	case VerifyUnverified:
+		v.unverified.Add(1)
+	default:
+		v.valid.Add(1)
+	}
+}
@@ -0,0 +1,236 @@
+// Package config loads and saves attie's user configuration.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Config is stored as JSON in the user config directory,
+// e.g. ~/.config/attie/config.json.
+type Config struct {
+	// Collections holds per collection NSID settings.
+	Collections map[string]*CollectionConfig `json:"collections,omitempty"`
+	// Theme names the color theme, see the "set theme" command.
+	Theme string `json:"theme,omitempty"`
+	// AppView is the service used for actor search, defaults to
+	// the public Bluesky AppView.
+	AppView string `json:"appview,omitempty"`
+	// Frontends map NSID prefixes to web apps. They are tried before
+	// DefaultFrontends, which are only used when none matches.
+	Frontends []Frontend `json:"frontends,omitempty"`
+	// JetStream holds settings for the event feed.
+	JetStream JetStreamConfig `json:"jetstream,omitzero"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// CollectionConfig holds view settings remembered for a collection.
+type CollectionConfig struct {
+	// Layout is "table" to open the collection as a table, otherwise a list.
+	Layout  string   `json:"layout,omitempty"`
+	Columns []Column `json:"columns,omitempty"`
+}
+
+// Column is a table column showing the value at a JSON path in the record.
+type Column struct {
+	Path  string `json:"path"`
+	Width int    `json:"width,omitempty"`
+}
+
+// Overflow policies for events arriving faster than the feed shows them.
+const (
+	// OverflowDrop discards events, counting them as dropped.
+	OverflowDrop = "drop"
+	// OverflowBlock stops reading from the connection until the feed
+	// catches up, which may get the connection closed by the server.
+	OverflowBlock = "block"
+)
+
+// DefaultBufferSize is the number of events the feed keeps by default.
+const DefaultBufferSize = 5000
+
+// JetStreamConfig holds settings for the JetStream event feed.
+type JetStreamConfig struct {
+	// URL is the subscribe endpoint of the JetStream instance to read,
+	// at.DefaultJetStreamURL by default.
+	URL string `json:"url,omitempty"`
+	// BufferSize is the number of events kept, the oldest are evicted
+	// beyond it. Defaults to DefaultBufferSize.
+	BufferSize int `json:"bufferSize,omitempty"`
+	// Overflow is OverflowDrop (the default) or OverflowBlock.
+	Overflow string `json:"overflow,omitempty"`
+	// DisableCompression asks JetStream for uncompressed messages instead
+	// of zstd.
+	DisableCompression bool `json:"disableCompression,omitempty"`
+	// DisableVerification shows firehose commits without checking their
+	// signatures and MST proofs.
+	DisableVerification bool `json:"disableVerification,omitempty"`
+}
+
+// Size returns the buffer size, applying the default.
+func (j JetStreamConfig) Size() int {
+	if j.BufferSize <= 0 {
+		return DefaultBufferSize
+	}
+	return j.BufferSize
+}
+
+// Compressed reports whether messages are requested zstd compressed, the
+// default.
+func (j JetStreamConfig) Compressed() bool {
+	return !j.DisableCompression
+}
+
+// Verified reports whether firehose commits are verified, the default.
+func (j JetStreamConfig) Verified() bool {
+	return !j.DisableVerification
+}
+
+// Blocking reports whether the overflow policy is OverflowBlock.
+func (j JetStreamConfig) Blocking() bool {
+	return j.Overflow == OverflowBlock
+}
+
+// Frontend maps identities, collections and records under an NSID prefix
+// to URLs of a web app. URL templates may use {did}, {handle}, {collection},
+// {rkey}, {uri} and {record.<path>} for fields of the record value. An empty
+// template falls through to the next matching frontend.
+type Frontend struct {
+	// Prefix is matched against the collection NSID, the longest match
+	// among the configured frontends wins, then among the defaults. An
+	// empty prefix matches everything.
+	Prefix     string `json:"prefix"`
+	Profile    string `json:"profile,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Record     string `json:"record,omitempty"`
+}
+
+var DefaultFrontends = []Frontend{
+	{
+		Prefix:  "app.bsky.",
+		Profile: "https://bsky.app/profile/{handle}",
+	},
+	{
+		Prefix: "app.bsky.actor.profile",
+		Record: "https://bsky.app/profile/{handle}",
+	},
+	{
+		Prefix: "app.bsky.feed.post",
+		Record: "https://bsky.app/profile/{handle}/post/{rkey}",
+	},
+	{
+		Prefix: "app.bsky.feed.generator",
+		Record: "https://bsky.app/profile/{handle}/feed/{rkey}",
+	},
+	{
+		Prefix: "app.bsky.graph.list",
+		Record: "https://bsky.app/profile/{handle}/lists/{rkey}",
+	},
+	{
+		Prefix: "app.bsky.graph.starterpack",
+		Record: "https://bsky.app/starter-pack/{handle}/{rkey}",
+	},
+	{
+		Prefix:  "sh.tangled.",
+		Profile: "https://tangled.sh/{handle}",
+	},
+	{
+		Prefix: "sh.tangled.repo",
+		Record: "https://tangled.sh/{handle}/{record.name}",
+	},
+	{
+		Prefix:     "",
+		Profile:    "https://pdsls.dev/at://{did}",
+		Collection: "https://pdsls.dev/at://{did}/{collection}",
+		Record:     "https://pdsls.dev/{uri}",
+	},
+}
+
+// AllFrontends returns the configured frontends followed by the defaults.
+func (c *Config) AllFrontends() []Frontend {
+	return append(append([]Frontend{}, c.Frontends...), DefaultFrontends...)
+}
+
+// Dir returns the directory attie's config is stored in.
+func Dir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find config dir: %w", err)
+	}
+	return filepath.Join(dir, "attie"), nil
+}
+
+// Default returns an empty config that is saved to path.
+func Default(path string) *Config {
+	return &Config{
+		Collections: map[string]*CollectionConfig{},
+		path:        path,
+	}
+}
+
+// Load reads the config from the user config directory. A missing file
+// yields the default config.
+func Load() (*Config, error) {
+	dir, err := Dir()
+	if err != nil {
+		return Default(""), err
+	}
+	return LoadFile(filepath.Join(dir, "config.json"))
+}
+
+// LoadFile reads the config at path. A missing file yields the default config.
+func LoadFile(path string) (*Config, error) {
+	c := Default(path)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := json.Unmarshal(data, c); err != nil {
+		return c, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if c.Collections == nil {
+		c.Collections = map[string]*CollectionConfig{}
+	}
+	return c, nil
+}
+
+// Save writes the config back to the file it was loaded from.
+func (c *Config) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.path == "" {
+		return errors.New("config has no path")
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+	if err := os.WriteFile(c.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// Collection returns the settings for a collection NSID, creating them if
+// needed.
+func (c *Config) Collection(nsid string) *CollectionConfig {
+	cc, ok := c.Collections[nsid]
+	if !ok {
+		cc = &CollectionConfig{}
+		c.Collections[nsid] = cc
+	}
+	return cc
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const maxHistory = 100
+
+// State is local data attie keeps between runs, stored as JSON in the user
+// state directory, e.g. ~/.local/state/attie/state.json.
+type State struct {
+	// History holds recently visited repos, collections and records, most
+	// recent first.
+	History   []Visit    `json:"history,omitempty"`
+	Bookmarks []Bookmark `json:"bookmarks,omitempty"`
+
+	mu   sync.Mutex
+	path string
+}
+
+// Visit is a repo, collection or record that was opened.
+type Visit struct {
+	URI   string    `json:"uri"`
+	Label string    `json:"label,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+// Bookmark pins an account or record with an optional note.
+type Bookmark struct {
+	URI     string    `json:"uri"`
+	Label   string    `json:"label,omitempty"`
+	Note    string    `json:"note,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// StateDir returns the directory attie's state is stored in, following
+// XDG_STATE_HOME where it applies.
+func StateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "attie"), nil
+	}
+	if runtime.GOOS == "linux" || runtime.GOOS == "freebsd" || runtime.GOOS == "openbsd" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find home dir: %w", err)
+		}
+		return filepath.Join(home, ".local", "state", "attie"), nil
+	}
+	return Dir()
+}
+
+// LoadState reads the state from the user state directory. A missing file
+// yields an empty state.
+func LoadState() (*State, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return &State{}, err
+	}
+	return LoadStateFile(filepath.Join(dir, "state.json"))
+}
+
+// LoadStateFile reads the state at path. A missing file yields an empty state.
+func LoadStateFile(path string) (*State, error) {
+	s := &State{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return s, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save writes the state back to the file it was loaded from.
+func (s *State) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		return errors.New("state has no path")
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create state dir: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+// AddVisit moves uri to the front of the history.
+func (s *State) AddVisit(uri, label string) {
+	hist := []Visit{{URI: uri, Label: label, Time: time.Now()}}
+	for _, v := range s.History {
+		if v.URI != uri && len(hist) < maxHistory {
+			hist = append(hist, v)
+		}
+	}
+	s.History = hist
+}
+
+// Bookmark adds or updates the bookmark for uri.
+func (s *State) Bookmark(uri, label, note string) {
+	for i, b := range s.Bookmarks {
+		if b.URI == uri {
+			s.Bookmarks[i].Label = label
+			s.Bookmarks[i].Note = note
+			return
+		}
+	}
+	s.Bookmarks = append(s.Bookmarks, Bookmark{URI: uri, Label: label, Note: note, Created: time.Now()})
+}
+
+// RemoveBookmark deletes the bookmark for uri.
+func (s *State) RemoveBookmark(uri string) {
+	for i, b := range s.Bookmarks {
+		if b.URI == uri {
+			s.Bookmarks = append(s.Bookmarks[:i], s.Bookmarks[i+1:]...)
+			return
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 
+	"github.com/treethought/attie/config"
 	"github.com/treethought/attie/ui"
 
 	"github.com/bluesky-social/indigo/atproto/identity"
@@ -25,14 +28,76 @@ func main() {
 	slog.Info("starting attie")
 
 	query := ""
-	if len(os.Args) > 1 {
+	var replay *replayArgs
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		replay, err = parseReplayArgs(os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, "usage: attie replay <file.jsonl.zst> [--speed 2x]")
+			os.Exit(2)
+		}
+	} else if len(os.Args) > 1 {
 		query = os.Args[1]
 	}
 
-	app := ui.NewApp(query)
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("failed to load config, using defaults", "error", err)
+	}
+
+	state, err := config.LoadState()
+	if err != nil {
+		slog.Error("failed to load state", "error", err)
+	}
+
+	app := ui.NewApp(query, cfg, state)
+	if replay != nil {
+		app.ReplayFile(replay.path, replay.speed)
+	}
+	defer app.Close()
 
 	p := tea.NewProgram(app, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		slog.Error("program error", "error", err)
 	}
 }
+
+type replayArgs struct {
+	path  string
+	speed float64
+}
+
+// parseReplayArgs parses the arguments of the replay command, a recording
+// and an optional --speed given before or after it.
+func parseReplayArgs(args []string) (*replayArgs, error) {
+	r := &replayArgs{speed: 1}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--speed" || arg == "-speed":
+			if i+1 >= len(args) {
+				return nil, fmt.Errorf("--speed requires a value")
+			}
+			i++
+			arg = "--speed=" + args[i]
+			fallthrough
+		case strings.HasPrefix(arg, "--speed="):
+			speed, err := ui.ParseSpeed(strings.TrimPrefix(arg, "--speed="))
+			if err != nil {
+				return nil, err
+			}
+			r.speed = speed
+		case r.path == "":
+			r.path = arg
+		default:
+			return nil, fmt.Errorf("unexpected argument %q", arg)
+		}
+	}
+	if r.path == "" {
+		return nil, fmt.Errorf("a recording file is required")
+	}
+	if _, err := os.Stat(r.path); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
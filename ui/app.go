@@ -2,7 +2,10 @@ package ui
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
 	comatproto "github.com/bluesky-social/indigo/api/atproto"
 	"github.com/bluesky-social/indigo/atproto/identity"
@@ -11,6 +14,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/treethought/attie/at"
+	"github.com/treethought/attie/config"
 )
 
 type AppContext struct {
@@ -21,62 +25,111 @@ type AppContext struct {
 }
 
 type App struct {
-	client       *at.Client
-	search       *CommandPallete
-	repoView     *RepoView
-	rlist        *RecordsList
-	recordView   *RecordView
-	jetEventView *JetStreamEventView
-	active       tea.Model
-	err          string
-	w, h         int
-	query        string
-	spinner      spinner.Model
-	loading      bool
-	actx         *AppContext
-
-	jetstream      *JetStreamView
-	jetSreamActive bool
-
-	// TODO better nav handling
-	// this currently only used for going back from jetstreamevent
-	lastView tea.Model
-}
-
-func NewApp(query string) *App {
-	search := &CommandPallete{}
-	repoView := NewRepoView()
+	cfg       *config.Config
+	state     *config.State
+	client    *at.Client
+	search    *CommandPallete
+	bookmarks *BookmarksView
+	// versions of records seen in all tabs, to diff updates against
+	history *recordHistory
+	err     string
+	status  string
+	w, h    int
+	query   string
+	spinner spinner.Model
+
+	// the current tab, whose views and context the App works on
+	*tab
+	tabs []*tab
+
+	// view to return to when the palette is dismissed or runs a command
+	paletteReturn    tea.Model
+	paletteReturnJet bool
+
+	// confirm is the question shown in the footer, answered by the next
+	// key press
+	confirm *confirmMsg
+	// osc52 is the clipboard sequence rendered with the footer until it
+	// has been written
+	osc52 string
+
+	// recording to replay on startup
+	replayPath  string
+	replaySpeed float64
+}
+
+func NewApp(query string, cfg *config.Config, state *config.State) *App {
+	client := at.NewClient("", cfg.AppView)
+	search := NewCommandPallete(client, cfg.AllFrontends(), state)
 	spin := spinner.New()
 	spin.Spinner = spinner.Dot
 
-	jc := at.NewJetstreamClient()
-	jv := NewJetStreamView(jc)
+	if err := applyTheme(cfg.Theme); err != nil {
+		slog.Error("Failed to apply theme", "error", err)
+	}
+
+	history := newRecordHistory()
+	t := newTab(cfg, search, history)
 	return &App{
-		query:        query,
-		client:       at.NewClient(""),
-		search:       search,
-		repoView:     repoView,
-		rlist:        NewRecordsList(nil),
-		recordView:   NewRecordView(false),
-		jetEventView: NewJetEventView(false),
-		active:       search,
-		spinner:      spin,
-		loading:      false,
-		actx:         &AppContext{},
-		jetstream:    jv,
+		history:   history,
+		cfg:       cfg,
+		state:     state,
+		bookmarks: NewBookmarksView(state),
+		query:     query,
+		client:    client,
+		search:    search,
+		spinner:   spin,
+		tab:       t,
+		tabs:      []*tab{t},
+	}
+}
+
+// ReplayFile starts the app replaying a JetStream recording instead of
+// searching.
+func (a *App) ReplayFile(path string, speed float64) {
+	a.replayPath = path
+	a.replaySpeed = speed
+}
+
+// Close stops the recordings of all tabs, flushing them to disk.
+func (a *App) Close() {
+	for _, t := range a.tabs {
+		t.jetstream.StopRecording()
 	}
 }
 
 func (a *App) Init() tea.Cmd {
-	a.loading = true
-	if id, err := syntax.ParseAtIdentifier(a.query); err == nil {
-		slog.Info("Starting with query", "id", id.String())
-		return a.fetchRepo(id.String())
+	if a.replayPath != "" {
+		a.showJetStream()
+		return a.jetstream.ReplayFile(a.replayPath, a.replaySpeed)
+	}
+	if a.query == "" {
+		return a.active.Init()
 	}
-	if uri, err := syntax.ParseATURI(a.query); err == nil {
-		if uri.Collection() == "" {
-			return a.fetchRepo(uri.Authority().String())
+	slog.Info("Starting with query", "query", a.query)
+	q, err := parseQuery(a.cfg.AllFrontends(), a.query)
+	if err != nil {
+		slog.Error("Invalid query", "query", a.query, "error", err)
+		a.search.err = err.Error()
+		return a.active.Init()
+	}
+	cmd := a.route(q)
+	return tea.Batch(a.active.Init(), cmd)
+}
+
+// route loads the view for a handle, DID, AT URI or CID, the same for
+// startup and palette queries.
+func (a *App) route(q parsedQuery) tea.Cmd {
+	switch q.kind {
+	case queryIdentifier:
+		a.loading = true
+		return a.fetchRepo(q.value)
+	case queryATURI:
+		uri, err := syntax.ParseATURI(q.value)
+		if err != nil {
+			return func() tea.Msg { return repoErrorMsg{err: err} }
 		}
+		a.loading = true
 		if uri.RecordKey().String() == "" {
 			id := uri.Authority().Handle().String()
 			if uri.Authority().IsDID() {
@@ -84,26 +137,49 @@ func (a *App) Init() tea.Cmd {
 			}
 			return a.fetchRecords(uri.Collection().String(), id)
 		}
-
-		slog.Info("Starting with query", "uri", uri.String())
 		return a.fetchRecord(uri.Collection().String(), uri.Authority().String(), uri.RecordKey().String())
+	case queryCID:
+		return a.findCID(q.value)
 	}
+	return nil
+}
 
-	a.loading = false
-	return a.active.Init()
+// findCID opens a loaded record or buffered JetStream event by CID. A CID
+// alone does not identify a repo, so only items already seen can be found.
+func (a *App) findCID(cid string) tea.Cmd {
+	for _, rec := range a.rlist.Records() {
+		if rec.Cid == cid {
+			return func() tea.Msg {
+				return recordSelectedMsg{record: &at.RecordWithIdentity{Identity: a.actx.identity, Record: rec}}
+			}
+		}
+	}
+	for _, evt := range a.jetstream.Events() {
+		if evt.Commit != nil && evt.Commit.CID == cid {
+			return func() tea.Msg { return jetEventSelectedMsg{evt: evt} }
+		}
+	}
+	return func() tea.Msg {
+		return repoErrorMsg{err: fmt.Errorf("CID %s not found in loaded records or events", cid)}
+	}
 }
 
 const footerHeight = 1
 
+// bodyHeight is the height left for views below the tab bar and above the
+// footer.
+func (a *App) bodyHeight() int {
+	return a.h - footerHeight - a.tabBarHeight()
+}
+
 func (a *App) resizeChildren() tea.Cmd {
 	cmds := []tea.Cmd{}
-	h := a.h - footerHeight
+	h := a.bodyHeight()
 	a.search.SetSize(a.w, h)
-	a.repoView.SetSize(a.w, h)
-	a.rlist.SetSize(a.w, h)
-	a.recordView.SetSize(a.w, h)
-	a.jetstream.SetSize(a.w, h)
-	a.jetEventView.SetSize(a.w, h)
+	a.bookmarks.SetSize(a.w, h)
+	for _, t := range a.tabs {
+		t.setSize(a.w, h)
+	}
 	return tea.Batch(cmds...)
 }
 
@@ -117,17 +193,31 @@ func (a *App) resetToSearch() tea.Cmd {
 	return a.search.Init()
 }
 
+// setJetStreamActive shows or hides the stream. Hiding it leaves it running
+// in the background, and showing it again returns to the same event.
 func (a *App) setJetStreamActive(active bool) tea.Cmd {
 	if active {
-		a.jetEventView.SetEvent(nil)
-		if a.active != a.jetEventView {
+		shown := a.jetSreamActive
+		a.streamReturn = nil
+		if a.active != a.jetEventView && a.active != a.search {
 			a.lastView = a.active
 		}
+		a.jetEventView.SetEvent(nil)
 		a.jetSreamActive = true
-		a.jetstream.SetSize(a.w, a.h)
-		if a.jetstream.Running() {
-			// pause but keep view and items visisble
+		a.jetstream.SetSize(a.w, a.bodyHeight())
+		a.jetstream.MarkRead()
+		switch {
+		case shown && a.jetstream.Running():
+			// pause but keep view and items visible
 			return a.jetstream.Stop()
+		case a.jetstream.Running():
+			return nil
+		case shown:
+			// pick up a stopped stream where it left off
+			return a.jetstream.Resume()
+		case len(a.jetstream.Events()) > 0:
+			// show what a stopped stream buffered
+			return nil
 		}
 
 		cxs := []string{}
@@ -142,20 +232,312 @@ func (a *App) setJetStreamActive(active bool) tea.Cmd {
 	}
 
 	a.jetSreamActive = false
-	// clear event view
 	a.jetEventView.SetEvent(nil)
-	cmds := []tea.Cmd{
-		a.jetstream.Stop(),
-		a.jetstream.Clear(),
-	}
 	if a.lastView != nil {
 		a.active = a.lastView
-	} else {
-		cmds = append(cmds, a.resetToSearch())
+		return nil
+	}
+	return a.resetToSearch()
+}
+
+// startJetStream shows the stream with the given filters, updating them in
+// place when it is already connected.
+func (a *App) startJetStream(cxs, dids []string) tea.Cmd {
+	a.showJetStream()
+	return a.jetstream.SetFilters(cxs, dids)
+}
+
+// showJetStream switches to the stream without connecting or pausing it.
+func (a *App) showJetStream() {
+	if a.active != a.jetEventView && a.active != a.search {
+		a.lastView = a.active
+	}
+	a.jetEventView.SetEvent(nil)
+	a.jetSreamActive = true
+	a.jetstream.SetSize(a.w, a.bodyHeight())
+	a.jetstream.MarkRead()
+}
+
+func (a *App) closePalette() {
+	if a.paletteReturn == nil {
+		return
+	}
+	a.active = a.paletteReturn
+	a.jetSreamActive = a.paletteReturnJet
+	a.paletteReturn = nil
+	if a.jetSreamActive {
+		a.jetstream.MarkRead()
 	}
-	return tea.Sequence(
-		cmds...,
-	)
+}
+
+// commands returns the palette commands of the view the palette was opened
+// from, followed by the global commands.
+func (a *App) commands() []command {
+	var view tea.Model = a.active
+	if a.jetSreamActive {
+		view = a.jetstream
+	} else if a.active == a.search && a.paletteReturn != nil {
+		view = a.paletteReturn
+		if a.paletteReturnJet {
+			view = a.jetstream
+		}
+	}
+	var cmds []command
+	if c, ok := view.(commander); ok {
+		cmds = append(cmds, c.Commands()...)
+	}
+	return append(cmds, a.globalCommands()...)
+}
+
+func (a *App) globalCommands() []command {
+	cmds := []command{
+		{name: "jetstream: open", run: func(string) tea.Cmd {
+			if a.jetSreamActive {
+				return nil
+			}
+			return a.setJetStreamActive(true)
+		}},
+		{name: "jetstream: filter collection", arg: "collection NSIDs, comma separated", run: func(arg string) tea.Cmd {
+			return a.filterJetStream(splitList(arg), a.jetstream.session.dids)
+		}},
+		{name: "jetstream: filter did", arg: "DIDs or handles, comma separated", run: func(arg string) tea.Cmd {
+			return a.filterJetStream(a.jetstream.session.collections, splitList(arg))
+		}},
+		{name: "jetstream: filter kind", arg: "kinds: " + strings.Join(eventKinds, ", "), run: func(arg string) tea.Cmd {
+			cmd, err := a.jetstream.SetKinds(splitList(arg))
+			if err != nil {
+				return errCmd(err)
+			}
+			a.showJetStream()
+			return cmd
+		}},
+		{name: "jetstream: clear filters", run: func(string) tea.Cmd {
+			cmd, _ := a.jetstream.SetKinds(nil)
+			return tea.Batch(cmd, a.startJetStream(nil, nil))
+		}},
+		{name: "jetstream: replay from", arg: "15m ago, timestamp or cursor", run: func(arg string) tea.Cmd {
+			cursor, err := parseReplayFrom(arg, time.Now())
+			if err != nil {
+				return errCmd(err)
+			}
+			a.showJetStream()
+			return a.jetstream.Replay(cursor)
+		}},
+		{name: "jetstream: stop", run: func(string) tea.Cmd {
+			return a.jetstream.Stop()
+		}},
+		{name: "firehose: connect", arg: "relay or PDS host (optional, default " + at.DefaultFirehoseHost + ")", optional: true, run: func(arg string) tea.Cmd {
+			a.showJetStream()
+			return a.jetstream.Firehose(arg)
+		}},
+		{name: "jetstream: connect", run: func(string) tea.Cmd {
+			a.showJetStream()
+			return a.jetstream.JetStream()
+		}},
+		{name: "jetstream: record", arg: "file (optional, .zst compresses)", optional: true, run: func(arg string) tea.Cmd {
+			return a.jetstream.Record(strings.TrimSpace(arg))
+		}},
+		{name: "jetstream: replay recording", arg: "file [speed, e.g. 2x or max]", run: func(arg string) tea.Cmd {
+			fields := strings.Fields(arg)
+			if len(fields) == 0 {
+				return errCmd(fmt.Errorf("a recording file is required"))
+			}
+			speed := 1.0
+			if len(fields) > 1 {
+				var err error
+				if speed, err = ParseSpeed(fields[1]); err != nil {
+					return errCmd(err)
+				}
+			}
+			a.showJetStream()
+			return a.jetstream.ReplayFile(fields[0], speed)
+		}},
+		{name: "open in browser", run: func(string) tea.Cmd {
+			url, err := webURL(a.cfg.Frontends, a.webTarget())
+			if err != nil {
+				return errCmd(err)
+			}
+			return openURLCmd(url)
+		}},
+		{name: "set theme", arg: "theme: " + strings.Join(themeNames(), ", "), run: func(arg string) tea.Cmd {
+			if err := applyTheme(arg); err != nil {
+				return errCmd(err)
+			}
+			a.cfg.Theme = arg
+			if err := a.cfg.Save(); err != nil {
+				return errCmd(err)
+			}
+			return statusCmd("theme set to " + arg)
+		}},
+		{name: "bookmarks", run: func(string) tea.Cmd { return a.showBookmarks() }},
+		{name: "tab: new", run: func(string) tea.Cmd { return a.openTab() }},
+		{name: "tab: close", run: func(string) tea.Cmd { return a.closeTab() }},
+		{name: "quit", run: func(string) tea.Cmd { return tea.Quit }},
+	}
+	if a.actx.identity != nil {
+		id := a.actx.identity
+		cmds = append(cmds, command{name: "export repo", arg: "file (optional, default " + repoFileName(id) + ")", optional: true, run: func(arg string) tea.Cmd {
+			return a.exportRepo(id, strings.TrimSpace(arg))
+		}})
+	}
+	if a.active != a.search || a.paletteReturn != nil {
+		cmds = append(cmds, command{name: "bookmark", run: func(string) tea.Cmd {
+			return a.bookmarkCurrent()
+		}})
+	}
+	return cmds
+}
+
+// filterJetStream resolves handles to DIDs and restarts the stream with
+// the filters.
+func (a *App) filterJetStream(cxs, ids []string) tea.Cmd {
+	return a.inTab(func() tea.Msg {
+		dids := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if strings.HasPrefix(id, "did:") {
+				dids = append(dids, id)
+				continue
+			}
+			idd, err := a.client.GetIdentity(context.Background(), id)
+			if err != nil {
+				return statusMsg{err: err}
+			}
+			dids = append(dids, idd.DID.String())
+		}
+		return jetStreamFilterMsg{collections: cxs, dids: dids}
+	})
+}
+
+// repoFileName is the default file a repo is exported to, named after the
+// handle.
+func repoFileName(id *identity.Identity) string {
+	if id.Handle == syntax.HandleInvalid {
+		return id.DID.String() + ".car"
+	}
+	return id.Handle.String() + ".car"
+}
+
+// exportRepo downloads the repo as a CAR file to path, or the default file
+// when empty, asking first when the file exists.
+func (a *App) exportRepo(id *identity.Identity, path string) tea.Cmd {
+	if path == "" {
+		path = repoFileName(id)
+	}
+	return confirmOverwrite(path, func(overwrite bool) tea.Cmd {
+		return func() tea.Msg {
+			car, err := a.client.GetRepoCAR(context.Background(), id.DID.String())
+			if err != nil {
+				return statusMsg{err: err}
+			}
+			f, err := createExportFile(path, overwrite)
+			if err != nil {
+				return statusMsg{err: err}
+			}
+			if _, err := f.Write(car); err != nil {
+				f.Close()
+				return statusMsg{err: fmt.Errorf("failed to write repo: %w", err)}
+			}
+			if err := f.Close(); err != nil {
+				return statusMsg{err: fmt.Errorf("failed to write repo: %w", err)}
+			}
+			return statusMsg{text: fmt.Sprintf("exported repo (%d bytes) to %s", len(car), path)}
+		}
+	})
+}
+
+// contextLabel describes the current repo, collection or record for
+// history and bookmarks.
+func (a *App) contextLabel(t webTarget) string {
+	label := t.handle
+	if label == "" || label == syntax.HandleInvalid.String() {
+		label = t.did
+	}
+	if t.collection != "" {
+		label += "/" + t.collection
+		if t.rkey != "" {
+			label += "/" + t.rkey
+		}
+	}
+	return label
+}
+
+// addVisit records the current repo, collection or record in the history.
+func (a *App) addVisit() {
+	if a.actx.identity == nil {
+		return
+	}
+	t := webTarget{
+		did:        a.actx.identity.DID.String(),
+		handle:     a.actx.identity.Handle.String(),
+		collection: a.actx.collection,
+	}
+	if a.actx.record != nil {
+		if uri, err := syntax.ParseATURI(a.actx.record.Uri); err == nil {
+			t.rkey = uri.RecordKey().String()
+		}
+	}
+	a.state.AddVisit(t.uri(), a.contextLabel(t))
+	a.saveState()
+}
+
+func (a *App) saveState() {
+	if err := a.state.Save(); err != nil {
+		slog.Error("Failed to save state", "error", err)
+	}
+}
+
+// bookmarkCurrent prompts for a note and bookmarks the current account or
+// record.
+func (a *App) bookmarkCurrent() tea.Cmd {
+	t := a.webTarget()
+	if t.did == "" {
+		return errCmd(fmt.Errorf("nothing to bookmark"))
+	}
+	// bookmark the account or a record, not a collection
+	if t.rkey == "" {
+		t.collection = ""
+	}
+	uri, label := t.uri(), a.contextLabel(t)
+	a.paletteReturn = a.active
+	a.paletteReturnJet = a.jetSreamActive
+	a.jetSreamActive = false
+	a.active = a.search
+	a.search.loading = false
+	return tea.Batch(a.search.Init(), a.search.runCommand(command{
+		name:     "bookmark " + label,
+		arg:      "note (optional)",
+		optional: true,
+		run: func(note string) tea.Cmd {
+			a.state.Bookmark(uri, label, note)
+			a.saveState()
+			return statusCmd("bookmarked " + label)
+		},
+	}))
+}
+
+func (a *App) showBookmarks() tea.Cmd {
+	if a.active != a.bookmarks {
+		a.bookmarksReturn = a.active
+		if a.active == a.search {
+			a.bookmarksReturn = nil
+		}
+	}
+	if a.jetSreamActive {
+		a.setJetStreamActive(false)
+	}
+	a.active = a.bookmarks
+	a.bookmarks.SetSize(a.w, a.bodyHeight())
+	return a.bookmarks.Init()
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
 }
 
 func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -165,23 +547,103 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.w = msg.Width
 		a.h = msg.Height
 		return a, a.resizeChildren()
+	case statusMsg:
+		if msg.err != nil {
+			slog.Error("Action failed", "error", msg.err)
+			a.status = queryErrStyle.Render(msg.err.Error())
+		} else {
+			a.status = msg.text
+		}
+		return a, nil
+	case clipboardMsg:
+		a.osc52 = msg.seq
+		a.status = "copied " + msg.label
+		return a, tea.Tick(osc52Hold, func(time.Time) tea.Msg { return clipboardClearMsg{seq: msg.seq} })
+	case clipboardClearMsg:
+		if a.osc52 == msg.seq {
+			a.osc52 = ""
+		}
+		return a, nil
+	case confirmMsg:
+		a.confirm = &msg
+		a.status = msg.question + " (y/n)"
+		return a, nil
 	case tea.KeyMsg:
+		a.status = ""
+		if c := a.confirm; c != nil && msg.String() != "ctrl+c" {
+			// any other key declines
+			a.confirm = nil
+			if msg.String() == "y" {
+				return a, c.yes
+			}
+			return a, nil
+		}
+		if !strings.HasPrefix(msg.String(), "ctrl+") && a.capturingInput() {
+			break
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
 			return a, tea.Quit
 		case "ctrl+k":
 			// keep jetstream active, and stop on search submit
+			if a.active != a.search || a.jetSreamActive {
+				a.paletteReturn = a.active
+				a.paletteReturnJet = a.jetSreamActive
+			}
+			a.search.SetCommands(a.commands())
 			a.jetSreamActive = false
 			a.active = a.search
 			a.search.loading = false
 			return a, a.search.Init()
 		case "ctrl+j":
 			return a, a.setJetStreamActive(true)
+		case "alt+t":
+			return a, a.openTab()
+		case "alt+w":
+			return a, a.closeTab()
+		case "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9":
+			return a, a.switchTab(int(msg.String()[4] - '1'))
+		case "o":
+			if a.active == a.search && !a.jetSreamActive {
+				break
+			}
+			url, err := webURL(a.cfg.Frontends, a.webTarget())
+			if err != nil {
+				return a, errCmd(err)
+			}
+			return a, openURLCmd(url)
+		case "b":
+			if a.active == a.search || a.active == a.bookmarks {
+				break
+			}
+			return a, a.bookmarkCurrent()
+		case "B":
+			return a, a.showBookmarks()
 		case "esc":
 			if a.jetSreamActive {
+				if a.jetstream.Back() {
+					return a, nil
+				}
 				return a, a.setJetStreamActive(false)
 			}
+			if b, ok := a.active.(backer); ok && b.Back() {
+				return a, nil
+			}
+			if a.streamReturn != nil && a.active == a.streamReturn {
+				a.active = a.search
+				if a.lastView != nil {
+					a.active = a.lastView
+				}
+				return a, a.setJetStreamActive(true)
+			}
 			switch a.active {
+			case a.bookmarks:
+				if a.bookmarksReturn != nil {
+					a.active = a.bookmarksReturn
+					a.bookmarksReturn = nil
+					return a, nil
+				}
+				return a, a.resetToSearch()
 			case a.repoView:
 				return a, a.resetToSearch()
 			case a.rlist:
@@ -209,20 +671,113 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
-	case searchSubmitMsg:
-		// parse for handle/DID or a record URI
-		id, err := syntax.ParseAtIdentifier(msg.identifier.String())
-		if err != nil {
-			slog.Error("Failed to parse identifier, should have caught during submission", "error", err)
+	case tabMsg:
+		if msg.tab == a.tab {
+			return a.Update(msg.msg)
+		}
+		if a.tabIndex(msg.tab) < 0 {
+			// tab was closed
 			return a, nil
 		}
-		if id.IsDID() || id.IsHandle() {
-			return a,
-				tea.Sequence(
-					a.setJetStreamActive(false),
-					a.fetchRepo(id.String()),
-				)
+		cur := a.tab
+		a.tab = msg.tab
+		_, cmd := a.Update(msg.msg)
+		a.tab = cur
+		return a, cmd
+
+	case eventMsg:
+		// streams keep reading while hidden and in tabs that are not shown
+		msg.visible = msg.src == a.jetstream && a.jetSreamActive
+		a.history.addEvent(msg.evt)
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case jetStreamErrorMsg:
+		msg.visible = msg.src == a.jetstream && a.jetSreamActive
+		for _, evt := range msg.evts {
+			a.history.addEvent(evt)
+		}
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case jetStreamConnectedMsg:
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case jetFilterMatchesMsg:
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case saveWidthsMsg:
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case projectionMsg:
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case refilterMsg:
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case verifiedMsg:
+		_, cmd := msg.src.Update(msg)
+		cmds := []tea.Cmd{cmd}
+		for _, t := range a.tabs {
+			if t.jetstream == msg.src {
+				cmds = append(cmds, t.jetEventView.refreshVerify(msg.res.Info))
+			}
+		}
+		return a, tea.Batch(cmds...)
+
+	case jetStreamReconnectMsg:
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case statsTickMsg:
+		_, cmd := msg.src.Update(msg)
+		return a, cmd
+
+	case runCommandMsg:
+		a.closePalette()
+		slog.Info("Running command", "command", msg.cmd.name, "arg", msg.arg)
+		return a, msg.cmd.run(msg.arg)
+
+	case paletteClosedMsg:
+		a.closePalette()
+		return a, nil
+
+	case jetStreamFilterMsg:
+		return a, a.startJetStream(msg.collections, msg.dids)
+
+	case editJetStreamFilterMsg:
+		return a, a.filterJetStream(msg.collections, msg.ids)
+
+	case openEventMsg:
+		if msg.record {
+			a.streamReturn = a.recordView
+			return a, a.route(parsedQuery{kind: queryATURI, value: eventURI(msg.evt)})
+		}
+		a.streamReturn = a.repoView
+		return a, a.route(parsedQuery{kind: queryIdentifier, value: msg.evt.Did})
+
+	case filterEventMsg:
+		cxs, dids := a.jetstream.session.collections, a.jetstream.session.dids
+		if msg.did != "" {
+			dids = []string{msg.did}
+		}
+		if msg.collection != "" {
+			cxs = []string{msg.collection}
 		}
+		return a, a.startJetStream(cxs, dids)
+
+	case searchSubmitMsg:
+		a.paletteReturn = nil
+		a.bookmarksReturn = nil
+		a.streamReturn = nil
+		// the stream keeps running in the background
+		a.jetSreamActive = false
+		return a, a.route(msg.query)
 
 	case repoLoadedMsg:
 		a.loading = false
@@ -230,8 +785,10 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.actx.repo = msg.repo.Repo
 		a.actx.collection = ""
 		a.actx.record = nil
+		a.jetSreamActive = false
+		a.addVisit()
 		cmd := a.repoView.SetRepo(msg.repo)
-		a.repoView.SetSize(a.w, a.h-footerHeight) // Set size before switching view
+		a.repoView.SetSize(a.w, a.bodyHeight()) // Set size before switching view
 		a.active = a.repoView
 		a.search.loading = false
 		return a, cmd
@@ -246,32 +803,68 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.actx.identity = msg.records.Identity
 		a.actx.collection = msg.records.Collection()
 		a.actx.record = nil
+		a.jetSreamActive = false
+		a.addVisit()
+		for _, rec := range msg.records.Records {
+			a.history.addRecord(rec)
+		}
 		cmd := a.rlist.SetRecords(msg.records.Records)
-		a.rlist.SetSize(a.w, a.h-footerHeight) // Set size before switching view
+		a.rlist.cursor = msg.records.Cursor
+		a.rlist.SetSize(a.w, a.bodyHeight()) // Set size before switching view
 		a.active = a.rlist
 		a.search.loading = false
 		return a, cmd
 
+	case loadMoreRecordsMsg:
+		if a.actx.identity == nil || a.actx.collection == "" {
+			return a, nil
+		}
+		return a, a.fetchRecordsPage(a.actx.collection, a.actx.identity.DID.String(), msg.cursor)
+
+	case recordsPageLoadedMsg:
+		if a.actx.identity == nil || a.actx.identity.DID.String() != msg.repo ||
+			a.actx.collection != msg.collection || a.rlist.cursor != msg.cursor {
+			return a, nil
+		}
+		a.rlist.cursor = msg.records.Cursor
+		for _, rec := range msg.records.Records {
+			a.history.addRecord(rec)
+		}
+		cmd := a.rlist.AppendRecords(msg.records.Records)
+		a.rlist.SetSize(a.w, a.bodyHeight())
+		return a, cmd
+
 	case recordSelectedMsg:
 		a.loading = false
-		a.actx.identity = msg.record.Identity
+		if msg.record.Identity != nil {
+			a.actx.identity = msg.record.Identity
+		}
 		a.actx.collection = msg.record.Record.Collection()
 		a.actx.record = msg.record.Record
-		a.recordView.SetRecord(msg.record.Record)
-		a.recordView.SetSize(a.w, a.h-footerHeight) // Set size before switching view
+		a.jetSreamActive = false
+		a.addVisit()
+		a.history.addRecord(msg.record.Record)
+		cmd := a.recordView.SetRecord(msg.record.Record)
+		a.recordView.SetSize(a.w, a.bodyHeight()) // Set size before switching view
 		a.active = a.recordView
-		return a, nil
+		return a, cmd
 
 	case jetEventSelectedMsg:
-		a.jetEventView.SetEvent(msg.evt)
-		a.jetEventView.SetSize(a.w, a.h-footerHeight)
+		cmd := a.jetEventView.SetEvent(msg.evt)
+		a.jetEventView.SetSize(a.w, a.bodyHeight())
 		a.active = a.jetEventView
 		a.jetSreamActive = false
+		return a, cmd
+
+	case jqResultMsg:
+		msg.view.setQueryResult(msg)
 		return a, nil
 
 	case repoErrorMsg:
+		a.loading = false
 		a.search.err = msg.err.Error()
 		a.search.loading = false
+		a.status = queryErrStyle.Render(msg.err.Error())
 		return a, nil
 	}
 
@@ -293,8 +886,67 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, tea.Batch(cmds...)
 }
 
+// webTarget describes the item currently shown, for opening in a web app.
+func (a *App) webTarget() webTarget {
+	var t webTarget
+	if a.actx.identity != nil {
+		t.did = a.actx.identity.DID.String()
+		t.handle = a.actx.identity.Handle.String()
+	}
+	setRecord := func(rec *at.Record) {
+		if rec == nil {
+			return
+		}
+		uri, err := syntax.ParseATURI(rec.Uri)
+		if err != nil {
+			return
+		}
+		if did, err := uri.Authority().AsDID(); err == nil && did.String() != t.did {
+			t.did = did.String()
+			t.handle = ""
+		}
+		t.collection = uri.Collection().String()
+		t.rkey = uri.RecordKey().String()
+		if rec.Value != nil {
+			t.record, _ = decodeJSON(*rec.Value)
+		}
+	}
+	setEvent := func(evt *at.Event) {
+		if evt == nil {
+			return
+		}
+		t = webTarget{did: evt.Did}
+		if evt.Commit != nil {
+			t.collection = evt.Commit.Collection
+			t.rkey = evt.Commit.RKey
+			if len(evt.Commit.Record) > 0 {
+				t.record, _ = decodeJSON(evt.Commit.Record)
+			}
+		}
+	}
+
+	if a.jetSreamActive {
+		if item, ok := a.jetstream.list.SelectedItem().(eventItem); ok {
+			setEvent(item.event())
+		}
+		return t
+	}
+	switch a.active {
+	case a.repoView:
+		t.collection = a.repoView.SelectedCollection()
+	case a.rlist:
+		t.collection = a.actx.collection
+		setRecord(a.rlist.selectedRecord())
+	case a.recordView:
+		setRecord(a.actx.record)
+	case a.jetEventView:
+		setEvent(a.jetEventView.evt)
+	}
+	return t
+}
+
 func (a *App) fetchRepo(repoId string) tea.Cmd {
-	return func() tea.Msg {
+	return a.inTab(func() tea.Msg {
 		slog.Info("Fetching repo", "repoId", repoId)
 		resp, err := a.client.GetRepo(context.Background(), repoId)
 		if err != nil {
@@ -303,23 +955,35 @@ func (a *App) fetchRepo(repoId string) tea.Cmd {
 		}
 		slog.Info("Repo loaded", "repo", resp.Repo.Handle)
 		return repoLoadedMsg{repo: resp}
-	}
+	})
 }
 
 func (a *App) fetchRecords(collection, repo string) tea.Cmd {
-	return func() tea.Msg {
-		recs, err := a.client.ListRecords(context.Background(), collection, repo)
+	return a.inTab(func() tea.Msg {
+		recs, err := a.client.ListRecords(context.Background(), collection, repo, "")
 		if err != nil {
 			slog.Error("Failed to list records", "error", err)
 			return repoErrorMsg{err: err}
 		}
 		slog.Info("Records loaded", "repo", repo, "collection", collection, "numRecords", len(recs.Records))
 		return recordsLoadedMsg{records: recs}
-	}
+	})
+}
+
+func (a *App) fetchRecordsPage(collection, repo, cursor string) tea.Cmd {
+	return a.inTab(func() tea.Msg {
+		recs, err := a.client.ListRecords(context.Background(), collection, repo, cursor)
+		if err != nil {
+			slog.Error("Failed to list records", "error", err)
+			return repoErrorMsg{err: err}
+		}
+		slog.Info("Records page loaded", "repo", repo, "collection", collection, "numRecords", len(recs.Records))
+		return recordsPageLoadedMsg{records: recs, repo: repo, collection: collection, cursor: cursor}
+	})
 }
 
 func (a *App) fetchRecord(collection, repo, rkey string) tea.Cmd {
-	return func() tea.Msg {
+	return a.inTab(func() tea.Msg {
 		rec, err := a.client.GetRecord(context.Background(), collection, repo, rkey)
 		if err != nil {
 			slog.Error("Failed to get record", "error", err)
@@ -329,17 +993,46 @@ func (a *App) fetchRecord(collection, repo, rkey string) tea.Cmd {
 		return recordSelectedMsg{
 			record: rec,
 		}
+	})
+}
+
+// inputCapturer is implemented by views that accept text input. While
+// capturing, only global ctrl keybindings are handled by the App.
+type inputCapturer interface {
+	CapturingInput() bool
+}
+
+// backer is implemented by views with their own navigation state, such as
+// an applied query. Back returns false when there is nothing to go back from.
+type backer interface {
+	Back() bool
+}
+
+func (a *App) capturingInput() bool {
+	var m tea.Model = a.active
+	if a.jetSreamActive {
+		m = a.jetstream
 	}
+	ic, ok := m.(inputCapturer)
+	return ok && ic.CapturingInput()
 }
 
 func (a *App) footer() string {
 	key := func(k string) string {
-		return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205")).Render(k)
+		return keyStyle.Render(k)
 	}
 	sep := dimStyle.Render(" · ")
 	content := key("esc") + dimStyle.Render(" back") +
 		sep + key("ctrl+k") + dimStyle.Render(" search") +
 		sep + key("ctrl+j") + dimStyle.Render(" jetstream")
+	if n := a.jetstream.Unread(); n > 0 && !a.jetSreamActive {
+		content += collectionStyle.Render(fmt.Sprintf(" %d new", n))
+	}
+	if a.status != "" {
+		status := lipgloss.NewStyle().MaxWidth(a.w - lipgloss.Width(content) - 1).Render(a.status)
+		gap := max(a.w-lipgloss.Width(status)-lipgloss.Width(content), 1)
+		return status + strings.Repeat(" ", gap) + content
+	}
 	return lipgloss.NewStyle().Width(a.w).Align(lipgloss.Right).Render(content)
 }
 
@@ -353,12 +1046,17 @@ func (a *App) View() string {
 	} else {
 		body = a.active.View()
 	}
-	return lipgloss.JoinVertical(lipgloss.Left, body, a.footer())
+	// a pending clipboard sequence takes no space
+	footer := a.footer() + a.osc52
+	if bar := a.tabBar(); bar != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, bar, body, footer)
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, body, footer)
 }
 
 // Message types
 type searchSubmitMsg struct {
-	identifier syntax.AtIdentifier
+	query parsedQuery
 }
 
 type repoLoadedMsg struct {
@@ -373,6 +1071,19 @@ type recordsLoadedMsg struct {
 	records *at.RecordsWithIdentity
 }
 
+type loadMoreRecordsMsg struct {
+	cursor string
+}
+
+// recordsPageLoadedMsg is a page of records after cursor, it is dropped
+// unless the list still shows the collection and ends at cursor.
+type recordsPageLoadedMsg struct {
+	records    *at.RecordsWithIdentity
+	repo       string
+	collection string
+	cursor     string
+}
+
 type recordSelectedMsg struct {
 	record *at.RecordWithIdentity
 }
@@ -380,3 +1091,22 @@ type recordSelectedMsg struct {
 type repoErrorMsg struct {
 	err error
 }
+
+type jetStreamFilterMsg struct {
+	collections []string
+	dids        []string
+}
+
+// statusMsg reports the outcome of an action in the footer until the next
+// key press.
+type statusMsg struct {
+	text string
+	err  error
+}
+
+// confirmMsg asks a yes or no question in the footer, running yes when it
+// is answered with y.
+type confirmMsg struct {
+	question string
+	yes      tea.Cmd
+}
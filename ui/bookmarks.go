@@ -0,0 +1,117 @@
+package ui
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/treethought/attie/config"
+)
+
+type bookmarkItem struct {
+	b config.Bookmark
+}
+
+func (b bookmarkItem) FilterValue() string {
+	return b.b.Label + " " + b.b.Note + " " + b.b.URI
+}
+func (b bookmarkItem) Title() string {
+	if b.b.Label != "" {
+		return b.b.Label
+	}
+	return b.b.URI
+}
+func (b bookmarkItem) Description() string {
+	if b.b.Note != "" {
+		return b.b.Note + dimStyle.Render("  "+b.b.URI)
+	}
+	return dimStyle.Render(b.b.URI)
+}
+
+// BookmarksView lists pinned accounts and records.
+type BookmarksView struct {
+	list  list.Model
+	state *config.State
+	w, h  int
+}
+
+func NewBookmarksView(state *config.State) *BookmarksView {
+	del := list.DefaultDelegate{
+		ShowDescription: true,
+		Styles:          list.NewDefaultItemStyles(),
+	}
+	del.SetHeight(2)
+
+	l := list.New(nil, del, 80, 20)
+	freeListKeys(&l.KeyMap)
+	freeListD(&l.KeyMap)
+	l.SetShowTitle(false)
+	l.SetShowStatusBar(false)
+	l.SetFilteringEnabled(true)
+	return &BookmarksView{list: l, state: state}
+}
+
+// Refresh reloads the list from the state.
+func (v *BookmarksView) Refresh() tea.Cmd {
+	items := make([]list.Item, len(v.state.Bookmarks))
+	for i, b := range v.state.Bookmarks {
+		items[i] = bookmarkItem{b: b}
+	}
+	return v.list.SetItems(items)
+}
+
+func (v *BookmarksView) header() string {
+	return jetstreamTitleStyle.Render(fmt.Sprintf("🔖  Bookmarks (%d)", len(v.state.Bookmarks)))
+}
+
+func (v *BookmarksView) SetSize(w, h int) {
+	v.w, v.h = w, h
+	v.list.SetSize(w, h-lipgloss.Height(v.header()))
+}
+
+// CapturingInput reports whether the list filter is being edited.
+func (v *BookmarksView) CapturingInput() bool {
+	return v.list.SettingFilter()
+}
+
+func (v *BookmarksView) Init() tea.Cmd {
+	return v.Refresh()
+}
+
+func (v *BookmarksView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && !v.list.SettingFilter() {
+		item, selected := v.list.SelectedItem().(bookmarkItem)
+		switch msg.String() {
+		case "enter":
+			if !selected {
+				return v, nil
+			}
+			q, err := parseQuery(nil, item.b.URI)
+			if err != nil {
+				return v, errCmd(err)
+			}
+			return v, func() tea.Msg { return searchSubmitMsg{query: q} }
+		case "d":
+			if !selected {
+				return v, nil
+			}
+			v.state.RemoveBookmark(item.b.URI)
+			if err := v.state.Save(); err != nil {
+				slog.Error("Failed to save state", "error", err)
+			}
+			return v, tea.Batch(v.Refresh(), statusCmd("removed bookmark "+item.Title()))
+		}
+	}
+	var cmd tea.Cmd
+	v.list, cmd = v.list.Update(msg)
+	return v, cmd
+}
+
+func (v *BookmarksView) View() string {
+	if len(v.state.Bookmarks) == 0 {
+		return lipgloss.JoinVertical(lipgloss.Left, v.header(), dimStyle.Render("  No bookmarks yet, press b to pin an account or record"))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, v.header(), v.list.View())
+}
@@ -0,0 +1,64 @@
+package ui
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/atotto/clipboard"
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// clipboardMsg sets the terminal clipboard with an OSC52 sequence, which
+// the App renders in the footer so it reaches the terminal with a frame
+// rather than interleaved with one.
+type clipboardMsg struct {
+	label string
+	seq   string
+}
+
+// clipboardClearMsg removes the sequence from the footer once it has been
+// written, so later frames do not set the clipboard again.
+type clipboardClearMsg struct {
+	seq string
+}
+
+// osc52Hold is how long the sequence stays in the footer, a few frames of
+// the renderer.
+const osc52Hold = 100 * time.Millisecond
+
+// copyCmd copies text to the clipboard. An OSC52 sequence is always sent so
+// copying works over SSH; locally the system clipboard is also set, as not
+// every terminal supports OSC52.
+func copyCmd(label, text string) tea.Cmd {
+	return func() tea.Msg {
+		if text == "" {
+			return statusMsg{err: fmt.Errorf("nothing to copy")}
+		}
+		if !isSSH() {
+			if err := clipboard.WriteAll(text); err != nil {
+				slog.Warn("Failed to set the system clipboard", "error", err)
+			}
+		}
+		return clipboardMsg{label: label, seq: osc52Sequence(text)}
+	}
+}
+
+func osc52Sequence(text string) string {
+	seq := osc52.New(text)
+	term := os.Getenv("TERM")
+	switch {
+	case os.Getenv("TMUX") != "":
+		seq = seq.Tmux()
+	case strings.HasPrefix(term, "screen"):
+		seq = seq.Screen()
+	}
+	return seq.String()
+}
+
+func isSSH() bool {
+	return os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != ""
+}
@@ -1,14 +1,19 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/treethought/attie/at"
+	"github.com/treethought/attie/config"
 )
 
 type RecordsList struct {
@@ -17,8 +22,46 @@ type RecordsList struct {
 	header     string
 	w, h       int
 	collection string
+	cfg        *config.Config
+	// cursor for the next page of records, empty when all pages are loaded
+	cursor string
+
+	// the prompt edits either a jq projection or the table columns
+	prompt     textinput.Model
+	promptKind promptKind
+	promptErr  string
+	projection string
+	// projectGen identifies the latest projection run, earlier results
+	// are dropped
+	projectGen    int
+	cancelProject context.CancelFunc
+	projecting    bool
+	table         *RecordsTable
+	tableMode     tableMode
+	// widthsGen identifies the last resize, saved once it is not followed
+	// by another
+	widthsGen int
+	export    exportPrompt
 }
 
+type promptKind int
+
+const (
+	promptNone promptKind = iota
+	promptProjection
+	promptColumns
+)
+
+type tableMode int
+
+const (
+	tableNone tableMode = iota
+	// tableColumns shows the configured JSON path columns
+	tableColumns
+	// tableProjection shows the results of a jq projection
+	tableProjection
+)
+
 type RecordListItem struct {
 	r      *at.Record
 	parsed syntax.ATURI
@@ -32,8 +75,14 @@ func NewRecordListItem(r *at.Record) RecordListItem {
 	}
 }
 
+// FilterValue includes the record JSON so the list filter can match on
+// record content, see fieldQueryFilter.
 func (r RecordListItem) FilterValue() string {
-	return r.parsed.RecordKey().String()
+	val := ""
+	if r.r.Value != nil {
+		val = string(*r.r.Value)
+	}
+	return r.parsed.RecordKey().String() + filterValueSep + val
 }
 func (r RecordListItem) Title() string {
 	return r.parsed.RecordKey().String()
@@ -50,7 +99,7 @@ func truncMiddle(s string, max int) string {
 	return s[:half] + "..." + s[len(s)-half:]
 }
 
-func NewRecordsList(records []*at.Record) *RecordsList {
+func NewRecordsList(cfg *config.Config, records []*at.Record) *RecordsList {
 	del := list.DefaultDelegate{
 		ShowDescription: true,
 		Styles:          list.NewDefaultItemStyles(),
@@ -58,12 +107,19 @@ func NewRecordsList(records []*at.Record) *RecordsList {
 	del.SetHeight(2)
 
 	l := list.New(nil, del, 80, 20)
+	freeListKeys(&l.KeyMap)
 	l.SetShowTitle(false)
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(true)
+	l.Filter = fieldQueryFilter
+	l.FilterInput.Placeholder = `text~"hello" subject.uri=at://...`
 	rl := &RecordsList{
 		rlist:   l,
 		preview: NewRecordView(true),
+		cfg:     cfg,
+		prompt:  textinput.New(),
+		table:   NewRecordsTable(),
+		export:  newExportPrompt(),
 	}
 	rl.SetRecords(records)
 	return rl
@@ -74,6 +130,7 @@ func (rl *RecordsList) SetRecords(records []*at.Record) tea.Cmd {
 		return nil
 	}
 	rl.preview.SetRecord(nil)
+	rl.stopProjection()
 	rl.rlist.SetItems(nil)
 	items := make([]list.Item, len(records))
 	for i, rec := range records {
@@ -83,11 +140,283 @@ func (rl *RecordsList) SetRecords(records []*at.Record) tea.Cmd {
 	cmd := rl.rlist.SetItems(items)
 	if len(items) > 0 {
 		rl.preview.SetRecord(items[0].(RecordListItem).r)
+		rl.collection = records[0].Collection()
+	}
+	rl.projection = ""
+	rl.promptKind = promptNone
+	rl.promptErr = ""
+	rl.tableMode = tableNone
+	if rl.collectionConfig().Layout == "table" {
+		rl.tableMode = tableColumns
+		rl.refreshTable()
 	}
 	rl.header = rl.buildHeader()
 	return cmd
 }
 
+// AppendRecords adds the next page of records to the list, keeping the
+// current selection and filter.
+func (rl *RecordsList) AppendRecords(records []*at.Record) tea.Cmd {
+	items := rl.rlist.Items()
+	for _, rec := range records {
+		items = append(items, NewRecordListItem(rec))
+	}
+	// SetItems re-runs any active filter across all loaded pages
+	cmd := rl.rlist.SetItems(items)
+	tcmd := rl.refreshTable()
+	rl.header = rl.buildHeader()
+	return tea.Batch(cmd, tcmd)
+}
+
+// Records returns all loaded records, ignoring any active filter.
+func (rl *RecordsList) Records() []*at.Record {
+	return recordsOf(rl.rlist.Items())
+}
+
+// visibleRecords returns the loaded records matching the active filter.
+func (rl *RecordsList) visibleRecords() []*at.Record {
+	return recordsOf(rl.rlist.VisibleItems())
+}
+
+func recordsOf(items []list.Item) []*at.Record {
+	records := make([]*at.Record, 0, len(items))
+	for _, it := range items {
+		if ri, ok := it.(RecordListItem); ok {
+			records = append(records, ri.r)
+		}
+	}
+	return records
+}
+
+// CapturingInput reports whether the list filter or prompt is being edited.
+func (rl *RecordsList) CapturingInput() bool {
+	return rl.rlist.SettingFilter() || rl.promptKind != promptNone || rl.export.active
+}
+
+// Back leaves a projection table, or the table layout.
+func (rl *RecordsList) Back() bool {
+	switch rl.tableMode {
+	case tableProjection:
+		rl.stopProjection()
+		rl.projection = ""
+		rl.tableMode = tableNone
+		if rl.collectionConfig().Layout == "table" {
+			rl.tableMode = tableColumns
+			rl.refreshTable()
+		}
+	case tableColumns:
+		rl.setLayout(tableNone)
+	default:
+		return false
+	}
+	rl.SetSize(rl.w, rl.h)
+	return true
+}
+
+func (rl *RecordsList) collectionConfig() *config.CollectionConfig {
+	if rl.cfg == nil || rl.collection == "" {
+		return &config.CollectionConfig{}
+	}
+	return rl.cfg.Collection(rl.collection)
+}
+
+func (rl *RecordsList) columns() []config.Column {
+	if cols := rl.collectionConfig().Columns; len(cols) > 0 {
+		return cols
+	}
+	return defaultColumns
+}
+
+func (rl *RecordsList) saveConfig() {
+	if rl.cfg == nil {
+		return
+	}
+	if err := rl.cfg.Save(); err != nil {
+		slog.Error("Failed to save config", "error", err)
+	}
+}
+
+// setLayout switches between the list and column table, remembering the
+// choice for the collection.
+func (rl *RecordsList) setLayout(mode tableMode) {
+	rl.tableMode = mode
+	cc := rl.collectionConfig()
+	if mode == tableColumns {
+		cc.Layout = "table"
+		rl.refreshTable()
+	} else {
+		cc.Layout = ""
+	}
+	rl.saveConfig()
+}
+
+// refreshTable rebuilds the table rows from the visible records, so any
+// active filter applies first. A projection is rebuilt in the background.
+func (rl *RecordsList) refreshTable() tea.Cmd {
+	switch rl.tableMode {
+	case tableColumns:
+		records := rl.visibleRecords()
+		names, widths, rows := tabulateRecords(rl.columns(), records)
+		rl.table.SetData(names, widths, rows, records)
+	case tableProjection:
+		return rl.project(rl.projection, false)
+	}
+	return nil
+}
+
+// projectionMsg is the outcome of a projection started by project.
+type projectionMsg struct {
+	src  *RecordsList
+	gen  int
+	expr string
+	// submit is set for projections entered in the prompt, which stays
+	// open until they succeed
+	submit bool
+	cols   []string
+	rows   [][]string
+	recs   []*at.Record
+	err    error
+}
+
+// project runs the jq projection over the visible records in the
+// background, as it may take long or not terminate.
+func (rl *RecordsList) project(expr string, submit bool) tea.Cmd {
+	rl.stopProjection()
+	ctx, cancel := context.WithTimeout(context.Background(), jqTimeout)
+	rl.cancelProject = cancel
+	rl.projecting = true
+	gen, records := rl.projectGen, rl.visibleRecords()
+	return func() tea.Msg {
+		defer cancel()
+		cols, rows, recs, err := projectRecords(ctx, expr, records)
+		return projectionMsg{src: rl, gen: gen, expr: expr, submit: submit, cols: cols, rows: rows, recs: recs, err: err}
+	}
+}
+
+// stopProjection cancels a running projection and drops its result.
+func (rl *RecordsList) stopProjection() {
+	if rl.cancelProject != nil {
+		rl.cancelProject()
+		rl.cancelProject = nil
+	}
+	rl.projectGen++
+	rl.projecting = false
+}
+
+// setProjection shows the result of the latest projection.
+func (rl *RecordsList) setProjection(msg projectionMsg) tea.Cmd {
+	if msg.gen != rl.projectGen {
+		return nil
+	}
+	rl.projecting = false
+	rl.cancelProject = nil
+	defer rl.SetSize(rl.w, rl.h)
+	if msg.err != nil {
+		if msg.submit {
+			rl.promptErr = msg.err.Error()
+			return nil
+		}
+		return errCmd(fmt.Errorf("failed to project records: %w", msg.err))
+	}
+	rl.projection = msg.expr
+	rl.tableMode = tableProjection
+	rl.table.SetData(msg.cols, nil, msg.rows, msg.recs)
+	if msg.submit && rl.promptKind == promptProjection {
+		rl.promptErr = ""
+		rl.closePrompt()
+	}
+	return nil
+}
+
+func (rl *RecordsList) toggleTable() {
+	if rl.tableMode == tableNone {
+		rl.setLayout(tableColumns)
+	} else {
+		rl.stopProjection()
+		rl.projection = ""
+		rl.setLayout(tableNone)
+	}
+	rl.SetSize(rl.w, rl.h)
+}
+
+// widthsSaveDelay is how long column widths must stay unchanged before
+// they are written to the config file.
+const widthsSaveDelay = time.Second
+
+// saveWidthsMsg saves the config unless widths changed again since.
+type saveWidthsMsg struct {
+	src *RecordsList
+	gen int
+}
+
+// saveWidths remembers resized column widths for the collection, saving
+// them once resizing stops.
+func (rl *RecordsList) saveWidths() tea.Cmd {
+	if rl.tableMode != tableColumns {
+		return nil
+	}
+	cols := append([]config.Column{}, rl.columns()...)
+	for i, w := range rl.table.Widths() {
+		if i < len(cols) {
+			cols[i].Width = w
+		}
+	}
+	rl.collectionConfig().Columns = cols
+	rl.widthsGen++
+	gen := rl.widthsGen
+	return tea.Tick(widthsSaveDelay, func(time.Time) tea.Msg { return saveWidthsMsg{src: rl, gen: gen} })
+}
+
+func (rl *RecordsList) openPrompt(kind promptKind) tea.Cmd {
+	rl.promptKind = kind
+	rl.promptErr = ""
+	switch kind {
+	case promptProjection:
+		rl.prompt.Prompt = "jq> "
+		rl.prompt.Placeholder = "{text, createdAt}"
+		rl.prompt.SetValue(rl.projection)
+	case promptColumns:
+		rl.prompt.Prompt = "columns> "
+		rl.prompt.Placeholder = "@rkey, createdAt, subject.uri"
+		rl.prompt.SetValue(formatColumns(rl.columns()))
+	}
+	rl.prompt.CursorEnd()
+	rl.SetSize(rl.w, rl.h)
+	return rl.prompt.Focus()
+}
+
+func (rl *RecordsList) closePrompt() {
+	rl.promptKind = promptNone
+	rl.prompt.Blur()
+	rl.SetSize(rl.w, rl.h)
+}
+
+func (rl *RecordsList) submitPrompt() tea.Cmd {
+	val := strings.TrimSpace(rl.prompt.Value())
+	switch rl.promptKind {
+	case promptProjection:
+		if val == "" {
+			rl.closePrompt()
+			rl.Back()
+			return nil
+		}
+		// the prompt closes once the projection succeeds
+		rl.promptErr = ""
+		return rl.project(val, true)
+	case promptColumns:
+		cols := parseColumns(val, rl.columns())
+		if len(cols) == 0 {
+			rl.promptErr = "at least one column is required"
+			return nil
+		}
+		rl.collectionConfig().Columns = cols
+		rl.setLayout(tableColumns)
+	}
+	rl.promptErr = ""
+	rl.closePrompt()
+	return nil
+}
+
 func (rl *RecordsList) buildHeader() string {
 	// TODO pass collection into model and fetch in init
 	// for now just use the first record's collection for header
@@ -106,6 +435,9 @@ func (rl *RecordsList) buildHeader() string {
 	s.WriteString(uri.Collection().String())
 	s.WriteString(" - ")
 	s.WriteString(fmt.Sprintf("%d records", len(rl.rlist.Items())))
+	if rl.cursor != "" {
+		s.WriteString(dimStyle.Render("  ·  n: load more"))
+	}
 	return lipgloss.NewStyle().Bold(true).Render(s.String())
 }
 
@@ -113,7 +445,11 @@ func (rl *RecordsList) SetSize(w, h int) {
 	rl.w = w
 	rl.h = h
 	headerHeight := lipgloss.Height(rl.header)
-	if rl.w > 100 {
+	if p := rl.promptView(); p != "" {
+		headerHeight += lipgloss.Height(p)
+	}
+	rl.table.SetSize(rl.w, rl.h-headerHeight)
+	if rl.w > 100 && rl.promptView() == "" {
 		rl.rlist.SetSize(rl.w/2, rl.h-headerHeight)
 		rl.preview.SetSize(rl.w/2, rl.h-headerHeight)
 		return
@@ -126,14 +462,164 @@ func (m *RecordsList) Init() tea.Cmd {
 	return nil
 }
 
+func (rl *RecordsList) updatePrompt(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "enter":
+			cmd := rl.submitPrompt()
+			rl.SetSize(rl.w, rl.h)
+			return cmd
+		case "esc":
+			if rl.promptKind == promptProjection {
+				rl.stopProjection()
+			}
+			rl.promptErr = ""
+			rl.closePrompt()
+			return nil
+		}
+	}
+	var cmd tea.Cmd
+	rl.prompt, cmd = rl.prompt.Update(msg)
+	return cmd
+}
+
+// openExport prompts to export the visible records. The table layout
+// defaults to CSV of its columns.
+func (rl *RecordsList) openExport() tea.Cmd {
+	name := "records"
+	if rl.collection != "" {
+		name = rl.collection
+	}
+	value := name + ".ndjson"
+	if rl.tableMode == tableColumns {
+		value = fmt.Sprintf("%s.csv %s", name, strings.Join(formatPaths(rl.columns()), ","))
+	}
+	cmd := rl.export.Open(value)
+	rl.SetSize(rl.w, rl.h)
+	return cmd
+}
+
+func (rl *RecordsList) updateExport(msg tea.Msg) tea.Cmd {
+	req, cmd := rl.export.Update(msg)
+	rl.SetSize(rl.w, rl.h)
+	if req == nil {
+		return cmd
+	}
+	return exportCmd(recordsExport(rl.visibleRecords(), formatPaths(rl.columns())), *req)
+}
+
+func (rl *RecordsList) Commands() []command {
+	cmds := append(recordCommands(rl.selectedRecord),
+		command{name: "export records", run: func(string) tea.Cmd { return rl.openExport() }},
+		command{name: "toggle table", run: func(string) tea.Cmd { rl.toggleTable(); return nil }},
+		command{name: "edit columns", run: func(string) tea.Cmd { return rl.openPrompt(promptColumns) }},
+		command{name: "project with jq", run: func(string) tea.Cmd { return rl.openPrompt(promptProjection) }},
+	)
+	if rl.cursor != "" {
+		cursor := rl.cursor
+		cmds = append(cmds, command{name: "load more records", run: func(string) tea.Cmd {
+			return func() tea.Msg { return loadMoreRecordsMsg{cursor: cursor} }
+		}})
+	}
+	return cmds
+}
+
+// selectedRecord returns the record selected in the list or table.
+func (rl *RecordsList) selectedRecord() *at.Record {
+	if rl.tableMode != tableNone {
+		return rl.table.SelectedRecord()
+	}
+	if item, ok := rl.rlist.SelectedItem().(RecordListItem); ok {
+		return item.r
+	}
+	return nil
+}
+
+// updateTableKeys handles keys shared by the list and table layouts.
+func (rl *RecordsList) updateTableKeys(msg tea.KeyMsg) (tea.Cmd, bool) {
+	switch msg.String() {
+	case "y", "Y", "J":
+		rec := rl.selectedRecord()
+		if rec == nil {
+			return nil, true
+		}
+		return copyRecordCmd(msg.String(), rec), true
+	case "e":
+		return rl.openExport(), true
+	case "|":
+		return rl.openPrompt(promptProjection), true
+	case "c":
+		return rl.openPrompt(promptColumns), true
+	case "t":
+		rl.toggleTable()
+		return nil, true
+	case "n":
+		if rl.cursor == "" {
+			return nil, true
+		}
+		cursor := rl.cursor
+		return func() tea.Msg {
+			return loadMoreRecordsMsg{cursor: cursor}
+		}, true
+	}
+	return nil, false
+}
+
+func (rl *RecordsList) updateTable(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		if cmd, ok := rl.updateTableKeys(msg); ok {
+			return cmd
+		}
+		if msg.String() == "enter" {
+			if rec := rl.table.SelectedRecord(); rec != nil {
+				return func() tea.Msg {
+					return recordSelectedMsg{
+						record: &at.RecordWithIdentity{Record: rec},
+					}
+				}
+			}
+		}
+	}
+	cmd, resized := rl.table.update(msg)
+	if resized {
+		return tea.Batch(cmd, rl.saveWidths())
+	}
+	return cmd
+}
+
 func (rl *RecordsList) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case saveWidthsMsg:
+		if msg.gen == rl.widthsGen {
+			rl.saveConfig()
+		}
+		return rl, nil
+	case projectionMsg:
+		return rl, rl.setProjection(msg)
+	}
+	if rl.promptKind != promptNone {
+		return rl, rl.updatePrompt(msg)
+	}
+	if rl.export.active {
+		return rl, rl.updateExport(msg)
+	}
+	if rl.tableMode != tableNone {
+		return rl, rl.updateTable(msg)
+	}
+	settingFilter := rl.rlist.SettingFilter()
 	var cmd tea.Cmd
 	rl.rlist, cmd = rl.rlist.Update(msg)
 	if item, ok := rl.rlist.SelectedItem().(RecordListItem); ok {
 		rl.preview.SetRecord(item.r)
 	}
+	if settingFilter {
+		return rl, cmd
+	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if kcmd, ok := rl.updateTableKeys(msg); ok {
+			return rl, tea.Batch(cmd, kcmd)
+		}
 		switch msg.String() {
 		case "enter":
 			if item, ok := rl.rlist.SelectedItem().(RecordListItem); ok {
@@ -151,7 +637,37 @@ func (rl *RecordsList) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return rl, cmd
 }
 
+func (rl *RecordsList) promptView() string {
+	if rl.export.active {
+		return rl.export.View()
+	}
+	if rl.promptKind == promptNone {
+		if rl.tableMode == tableProjection {
+			return dimStyle.Render("jq> " + rl.projection)
+		}
+		return ""
+	}
+	s := rl.prompt.View()
+	if rl.promptKind == promptProjection && rl.projecting {
+		s += dimStyle.Render("  running…")
+	}
+	if rl.promptErr != "" {
+		s = lipgloss.JoinVertical(lipgloss.Left, s, queryErrStyle.Render(rl.promptErr))
+	}
+	return s
+}
+
 func (rl *RecordsList) View() string {
+	body := rl.rlist.View()
+	if rl.tableMode != tableNone {
+		body = rl.table.View()
+	}
+	if p := rl.promptView(); p != "" {
+		return lipgloss.JoinVertical(lipgloss.Left, rl.header, p, body)
+	}
+	if rl.tableMode != tableNone {
+		return lipgloss.JoinVertical(lipgloss.Left, rl.header, body)
+	}
 	if rl.w > 100 {
 		return lipgloss.JoinVertical(lipgloss.Left, rl.header, lipgloss.JoinHorizontal(lipgloss.Top,
 			lipgloss.JoinVertical(lipgloss.Left, rl.header, rl.rlist.View()),
@@ -0,0 +1,56 @@
+package ui
+
+import (
+	"encoding/json"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/treethought/attie/at"
+)
+
+func TestRecordsListProjection(t *testing.T) {
+	val := json.RawMessage(`{"text":"hello"}`)
+	rl := NewRecordsList(nil, []*at.Record{{
+		Uri:   "at://did:plc:abc/app.bsky.feed.post/3k",
+		Cid:   "bafy",
+		Value: &val,
+	}})
+	rl.SetSize(80, 24)
+	submit := func(expr string) tea.Cmd {
+		t.Helper()
+		rl.openPrompt(promptProjection)
+		rl.prompt.SetValue(expr)
+		_, cmd := rl.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		if cmd == nil {
+			t.Fatalf("submitting %q did not start the projection", expr)
+		}
+		return cmd
+	}
+
+	stale := submit(".text")
+	cmd := submit("{text}")
+	if rl.promptKind != promptProjection || rl.tableMode != tableNone {
+		t.Fatal("the projection was applied before it finished")
+	}
+	// the result of the replaced projection is dropped
+	rl.Update(stale())
+	if rl.tableMode != tableNone {
+		t.Fatal("a replaced projection was applied")
+	}
+	rl.Update(cmd())
+	if rl.promptKind != promptNone || rl.tableMode != tableProjection || rl.projection != "{text}" {
+		t.Fatalf("prompt %v, mode %v, projection %q after the result", rl.promptKind, rl.tableMode, rl.projection)
+	}
+	if got := rl.table.SelectedRecord(); got == nil || got.Cid != "bafy" {
+		t.Errorf("table shows %v, want the projected record", got)
+	}
+
+	// an invalid expression keeps the prompt open with its error
+	rl.Update(submit(".[")())
+	if rl.promptKind != promptProjection || rl.promptErr == "" {
+		t.Errorf("invalid projection closed the prompt, error %q", rl.promptErr)
+	}
+	if rl.projection != "{text}" {
+		t.Errorf("projection = %q, want the last good one", rl.projection)
+	}
+}
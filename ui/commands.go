@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"github.com/sahilm/fuzzy"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// command is a named action run from the palette's command mode.
+type command struct {
+	name string
+	// arg describes the argument the palette prompts for, if any
+	arg      string
+	optional bool
+	run      func(arg string) tea.Cmd
+}
+
+// commander is implemented by views that register palette commands.
+type commander interface {
+	Commands() []command
+}
+
+// runCommandMsg is sent by the palette to run a command once the App has
+// returned to the view it was opened from.
+type runCommandMsg struct {
+	cmd command
+	arg string
+}
+
+// paletteClosedMsg is sent when the palette is dismissed without running
+// anything.
+type paletteClosedMsg struct{}
+
+// matchCommands fuzzy matches commands by name, best matches first.
+func matchCommands(cmds []command, pattern string) []command {
+	if pattern == "" {
+		return cmds
+	}
+	names := make([]string, len(cmds))
+	for i, c := range cmds {
+		names[i] = c.name
+	}
+	matches := fuzzy.Find(pattern, names)
+	out := make([]command, len(matches))
+	for i, m := range matches {
+		out[i] = cmds[m.Index]
+	}
+	return out
+}
+
+// statusCmd reports a message in the footer.
+func statusCmd(text string) tea.Cmd {
+	return func() tea.Msg { return statusMsg{text: text} }
+}
+
+// errCmd reports an error in the footer.
+func errCmd(err error) tea.Cmd {
+	return func() tea.Msg { return statusMsg{err: err} }
+}
@@ -1,42 +1,207 @@
 package ui
 
 import (
+	"context"
+	"errors"
+
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 // ContentView wraps a scrollable viewport with a header line.
+// Non-preview views also provide a jq query pane over JSON content.
 type ContentView struct {
 	vp      viewport.Model
 	preview bool
 	header  string
 	empty   bool
+
+	content string
+	// alt is shown instead of the content while set and no query is applied
+	alt      string
+	doc      any
+	query    textinput.Model
+	querying bool
+	queryErr string
+	// queryGen identifies the latest query run, earlier results are dropped
+	queryGen    int
+	cancelQuery context.CancelFunc
+	running     bool
+	// raw shows the content without the applied query
+	raw  bool
+	w, h int
+
+	export exportPrompt
+	// exporter returns the data to export and a default file name
+	exporter func() (exportData, string)
 }
 
 func newContentView(preview bool) ContentView {
+	qi := textinput.New()
+	qi.Prompt = "jq> "
+	qi.Placeholder = ".text"
+	vp := viewport.New(80, 20)
+	freeViewportKeys(&vp.KeyMap)
 	return ContentView{
-		vp:      viewport.New(80, 20),
+		vp:      vp,
 		preview: preview,
 		empty:   true,
+		query:   qi,
+		export:  newExportPrompt(),
 	}
 }
 
-func (v *ContentView) Set(header, content string) {
+func (v *ContentView) Set(header, content string) tea.Cmd {
 	if header == "" && content == "" {
 		v.empty = true
 		v.header = ""
+		v.content = ""
+		v.alt = ""
+		v.doc = nil
+		v.stopQuery()
 		v.vp.SetContent("")
-		return
+		return nil
 	}
 	v.empty = false
 	v.header = header
-	v.vp.SetContent(content)
+	v.content = content
+	v.doc, _ = decodeJSON([]byte(content))
+	return v.applyQuery()
+}
+
+// SetAlt shows alt in place of the content, or the content again when empty.
+// Queries still run against the content.
+func (v *ContentView) SetAlt(alt string) tea.Cmd {
+	v.alt = alt
+	return v.applyQuery()
+}
+
+// jqResultMsg is the outcome of a query started by applyQuery.
+type jqResultMsg struct {
+	view *ContentView
+	gen  int
+	out  string
+	err  error
+}
+
+// applyQuery renders the current content through the jq query, if any.
+// Queries run in the background, as they may take long or not terminate;
+// the last good result stays shown until the result arrives.
+func (v *ContentView) applyQuery() tea.Cmd {
+	v.stopQuery()
+	expr := v.query.Value()
+	if expr == "" || v.doc == nil || v.raw {
+		v.queryErr = ""
+		if v.alt != "" && expr == "" {
+			v.vp.SetContent(v.alt)
+			return nil
+		}
+		v.vp.SetContent(v.content)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), jqTimeout)
+	v.cancelQuery = cancel
+	v.running = true
+	gen, doc := v.queryGen, v.doc
+	return func() tea.Msg {
+		defer cancel()
+		results, err := runJQ(ctx, expr, doc)
+		msg := jqResultMsg{view: v, gen: gen, err: err}
+		if err == nil || errors.Is(err, errJQLimit) {
+			msg.out = formatJQResults(results)
+		}
+		if err != nil && msg.out != "" {
+			msg.out += "\n… " + err.Error()
+			msg.err = nil
+		}
+		return msg
+	}
+}
+
+// stopQuery cancels a running query and drops its result.
+func (v *ContentView) stopQuery() {
+	if v.cancelQuery != nil {
+		v.cancelQuery()
+		v.cancelQuery = nil
+	}
+	v.queryGen++
+	v.running = false
+}
+
+// setQueryResult shows the result of the latest query.
+func (v *ContentView) setQueryResult(msg jqResultMsg) {
+	if msg.gen != v.queryGen {
+		return
+	}
+	v.running = false
+	v.cancelQuery = nil
+	if msg.err != nil {
+		// keep showing the last good result while the expression is typed
+		v.queryErr = msg.err.Error()
+	} else {
+		v.queryErr = ""
+		v.vp.SetContent(msg.out)
+		v.vp.GotoTop()
+	}
+	v.SetSize(v.w, v.h)
 }
 
 func (v *ContentView) SetSize(w, h int) {
+	v.w, v.h = w, h
 	v.vp.Width = w
-	v.vp.Height = h - lipgloss.Height(v.header)
+	v.vp.Height = h - lipgloss.Height(v.header) - lipgloss.Height(v.queryView()) - lipgloss.Height(v.export.View())
+	v.query.Width = w - len(v.query.Prompt) - 1
+}
+
+// CapturingInput reports whether the jq query or export prompt is being edited.
+func (v *ContentView) CapturingInput() bool {
+	return v.querying || v.export.active
+}
+
+// Back clears an applied query.
+func (v *ContentView) Back() bool {
+	if v.query.Value() == "" {
+		return false
+	}
+	v.query.SetValue("")
+	// without a query the content is shown right away
+	v.applyQuery()
+	v.SetSize(v.w, v.h)
+	return true
+}
+
+func (v *ContentView) openQuery() tea.Cmd {
+	v.querying = true
+	v.raw = false
+	v.SetSize(v.w, v.h)
+	return v.query.Focus()
+}
+
+func (v *ContentView) openExport() tea.Cmd {
+	if v.exporter == nil || v.empty {
+		return nil
+	}
+	_, name := v.exporter()
+	cmd := v.export.Open(name)
+	v.SetSize(v.w, v.h)
+	return cmd
+}
+
+// toggleRaw switches between the query result and the raw content.
+func (v *ContentView) toggleRaw() tea.Cmd {
+	v.raw = !v.raw
+	return v.applyQuery()
+}
+
+// contentCommands are the palette commands shared by content views.
+func (v *ContentView) contentCommands(noun string) []command {
+	return []command{
+		{name: "query with jq", run: func(string) tea.Cmd { return v.openQuery() }},
+		{name: "toggle raw", run: func(string) tea.Cmd { return v.toggleRaw() }},
+		{name: "export " + noun, run: func(string) tea.Cmd { return v.openExport() }},
+	}
 }
 
 func (v *ContentView) initVP() tea.Cmd {
@@ -44,14 +209,87 @@ func (v *ContentView) initVP() tea.Cmd {
 }
 
 func (v *ContentView) updateVP(msg tea.Msg) tea.Cmd {
+	if v.querying {
+		return v.updateQuery(msg)
+	}
+	if v.export.active {
+		req, cmd := v.export.Update(msg)
+		v.SetSize(v.w, v.h)
+		if req != nil {
+			d, _ := v.exporter()
+			return exportCmd(d, *req)
+		}
+		return cmd
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok && !v.preview && !v.empty {
+		switch msg.String() {
+		case "|":
+			return v.openQuery()
+		case "e":
+			return v.openExport()
+		}
+	}
 	var cmd tea.Cmd
 	v.vp, cmd = v.vp.Update(msg)
 	return cmd
 }
 
+func (v *ContentView) updateQuery(msg tea.Msg) tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "enter":
+			v.querying = false
+			v.query.Blur()
+			v.SetSize(v.w, v.h)
+			return nil
+		case "esc":
+			v.querying = false
+			v.query.Blur()
+			v.query.SetValue("")
+			v.applyQuery()
+			v.SetSize(v.w, v.h)
+			return nil
+		}
+	}
+	prev := v.query.Value()
+	var cmd, qcmd tea.Cmd
+	v.query, cmd = v.query.Update(msg)
+	if v.query.Value() != prev {
+		qcmd = v.applyQuery()
+		v.SetSize(v.w, v.h)
+	}
+	return tea.Batch(cmd, qcmd)
+}
+
+var queryErrStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+func (v *ContentView) queryView() string {
+	if !v.querying && v.query.Value() == "" {
+		return ""
+	}
+	s := v.query.View()
+	if v.raw {
+		s += dimStyle.Render("  (raw)")
+	} else if v.running {
+		s += dimStyle.Render("  running…")
+	}
+	if v.queryErr != "" {
+		s = lipgloss.JoinVertical(lipgloss.Left, s, queryErrStyle.Render(v.queryErr))
+	}
+	return s
+}
+
 func (v *ContentView) renderVP() string {
 	if v.empty {
 		return ""
 	}
-	return lipgloss.JoinVertical(lipgloss.Left, v.header, v.vp.View())
+	parts := []string{v.header}
+	if q := v.queryView(); q != "" {
+		parts = append(parts, q)
+	}
+	if e := v.export.View(); e != "" {
+		parts = append(parts, e)
+	}
+	parts = append(parts, v.vp.View())
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
 }
@@ -0,0 +1,203 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky-social/jetstream/pkg/models"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/treethought/attie/at"
+)
+
+const (
+	// records whose versions are remembered before the oldest are forgotten
+	maxHistoryRecords = 10_000
+	// versions remembered per record
+	maxRecordVersions = 4
+)
+
+type recordVersion struct {
+	cid   string
+	value json.RawMessage
+}
+
+// recordHistory remembers versions of records seen in streams and loaded
+// from repos, so that updates can be diffed against the version before them.
+// JetStream only sends the new version and repos only serve the current one.
+type recordHistory struct {
+	versions map[string][]recordVersion
+	// URIs in the order they were first seen, for eviction
+	order []string
+}
+
+func newRecordHistory() *recordHistory {
+	return &recordHistory{versions: map[string][]recordVersion{}}
+}
+
+// add remembers a version of the record at uri, unless it is already known.
+func (h *recordHistory) add(uri, cid string, value json.RawMessage) {
+	if uri == "" || cid == "" || len(value) == 0 {
+		return
+	}
+	versions, ok := h.versions[uri]
+	if slices.ContainsFunc(versions, func(v recordVersion) bool { return v.cid == cid }) {
+		return
+	}
+	if !ok {
+		h.order = append(h.order, uri)
+		if len(h.order) > maxHistoryRecords {
+			delete(h.versions, h.order[0])
+			h.order = h.order[1:]
+		}
+	}
+	versions = append(versions, recordVersion{cid: cid, value: value})
+	if len(versions) > maxRecordVersions {
+		versions = versions[1:]
+	}
+	h.versions[uri] = versions
+}
+
+func (h *recordHistory) addEvent(evt *at.Event) {
+	if evt.Commit == nil || evt.Commit.Operation == models.CommitOperationDelete {
+		return
+	}
+	h.add(eventURI(evt), evt.Commit.CID, evt.Commit.Record)
+}
+
+func (h *recordHistory) addRecord(rec *at.Record) {
+	if rec == nil || rec.Value == nil {
+		return
+	}
+	h.add(rec.Uri, rec.Cid, *rec.Value)
+}
+
+// prior returns the version seen before the one with cid.
+func (h *recordHistory) prior(uri, cid string) (recordVersion, bool) {
+	versions := h.versions[uri]
+	i := slices.IndexFunc(versions, func(v recordVersion) bool { return v.cid == cid })
+	if i <= 0 {
+		return recordVersion{}, false
+	}
+	return versions[i-1], true
+}
+
+// jsonChange is a value added, removed or changed at a path of a document.
+type jsonChange struct {
+	path     string
+	old, new any
+	// set when the value is absent on that side
+	added, removed bool
+}
+
+// diffJSON collects the changes from a to b. Objects are compared by key and
+// arrays by index.
+func diffJSON(path string, a, b any, out *[]jsonChange) {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(a)+len(b))
+			for k := range a {
+				keys = append(keys, k)
+			}
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			slices.Sort(keys)
+			for _, k := range keys {
+				av, inA := a[k]
+				bv, inB := b[k]
+				p := joinPath(path, k)
+				switch {
+				case !inA:
+					*out = append(*out, jsonChange{path: p, new: bv, added: true})
+				case !inB:
+					*out = append(*out, jsonChange{path: p, old: av, removed: true})
+				default:
+					diffJSON(p, av, bv, out)
+				}
+			}
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			for i := range max(len(a), len(b)) {
+				p := joinPath(path, strconv.Itoa(i))
+				switch {
+				case i >= len(a):
+					*out = append(*out, jsonChange{path: p, new: b[i], added: true})
+				case i >= len(b):
+					*out = append(*out, jsonChange{path: p, old: a[i], removed: true})
+				default:
+					diffJSON(p, a[i], b[i], out)
+				}
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*out = append(*out, jsonChange{path: path, old: a, new: b})
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+var (
+	diffAddStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	diffRemoveStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+// recordDiff renders the changes between two versions of a record, one
+// path per change.
+func recordDiff(prev, cur json.RawMessage) (string, int, error) {
+	var a, b any
+	if err := json.Unmarshal(prev, &a); err != nil {
+		return "", 0, fmt.Errorf("invalid previous version: %w", err)
+	}
+	if err := json.Unmarshal(cur, &b); err != nil {
+		return "", 0, fmt.Errorf("invalid record: %w", err)
+	}
+	var changes []jsonChange
+	diffJSON("", a, b, &changes)
+	if len(changes) == 0 {
+		return dimStyle.Render("no changes"), 0, nil
+	}
+	lines := make([]string, 0, len(changes)*3)
+	for _, c := range changes {
+		path := c.path
+		if path == "" {
+			path = "."
+		}
+		switch {
+		case c.added:
+			lines = append(lines, diffAddStyle.Render("+ "+path+": "+compactJSON(c.new)))
+		case c.removed:
+			lines = append(lines, diffRemoveStyle.Render("- "+path+": "+compactJSON(c.old)))
+		default:
+			lines = append(lines,
+				accountStyle.Render("~ "+path),
+				diffRemoveStyle.Render("    - "+compactJSON(c.old)),
+				diffAddStyle.Render("    + "+compactJSON(c.new)),
+			)
+		}
+	}
+	return strings.Join(lines, "\n"), len(changes), nil
+}
+
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,263 @@
+package ui
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/treethought/attie/at"
+)
+
+type exportFormat int
+
+const (
+	exportJSON exportFormat = iota
+	exportNDJSON
+	exportCSV
+)
+
+// exportFormatFor infers the export format from the file extension.
+func exportFormatFor(path string) (exportFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return exportJSON, nil
+	case ".ndjson", ".jsonl":
+		return exportNDJSON, nil
+	case ".csv":
+		return exportCSV, nil
+	}
+	return 0, fmt.Errorf("unknown export format %q, use .json, .ndjson or .csv", filepath.Ext(path))
+}
+
+// exportData is a set of JSON documents along with how to render them as
+// CSV cells.
+type exportData struct {
+	docs    []any
+	columns []string
+	cell    func(i int, column string) string
+	// single is the export of a view of one document, which is written as
+	// JSON by itself rather than in an array
+	single bool
+}
+
+func recordsExport(records []*at.Record, columns []string) exportData {
+	docs := make([]any, len(records))
+	values := make([]any, len(records))
+	for i, r := range records {
+		docs[i] = r
+		if r.Value != nil {
+			values[i], _ = decodeJSON(*r.Value)
+		}
+	}
+	return exportData{
+		docs:    docs,
+		columns: columns,
+		cell: func(i int, column string) string {
+			return recordCell(records[i], values[i], column)
+		},
+	}
+}
+
+var defaultEventColumns = []string{"time_us", "did", "kind", "commit.operation", "commit.collection", "commit.rkey", "identity.handle", "account.active", "account.status"}
+
+func eventsExport(evts []*at.Event) exportData {
+	docs := make([]any, len(evts))
+	decoded := make([]any, len(evts))
+	for i, evt := range evts {
+		// encoded now, as the feed may update events while the export is
+		// written in the background
+		data, err := json.Marshal(evt)
+		if err != nil {
+			docs[i] = evt
+			continue
+		}
+		docs[i] = json.RawMessage(data)
+		decoded[i], _ = decodeJSON(data)
+	}
+	return exportData{
+		docs:    docs,
+		columns: defaultEventColumns,
+		cell: func(i int, column string) string {
+			v, ok := lookupPath(decoded[i], column)
+			if !ok {
+				return ""
+			}
+			return tableCell(v)
+		},
+	}
+}
+
+// write exports the data to path in the format given by its extension.
+// columns overrides the default CSV columns when set. An existing file is
+// only replaced when overwrite is set.
+func (d exportData) write(path string, columns []string, overwrite bool) error {
+	format, err := exportFormatFor(path)
+	if err != nil {
+		return err
+	}
+	f, err := createExportFile(path, overwrite)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer f.Close()
+
+	switch format {
+	case exportJSON:
+		var v any = d.docs
+		if d.single && len(d.docs) == 1 {
+			v = d.docs[0]
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(v); err != nil {
+			return fmt.Errorf("failed to write json: %w", err)
+		}
+	case exportNDJSON:
+		enc := json.NewEncoder(f)
+		for _, doc := range d.docs {
+			if err := enc.Encode(doc); err != nil {
+				return fmt.Errorf("failed to write ndjson: %w", err)
+			}
+		}
+	case exportCSV:
+		if len(columns) == 0 {
+			columns = d.columns
+		}
+		w := csv.NewWriter(f)
+		if err := w.Write(columns); err != nil {
+			return fmt.Errorf("failed to write csv: %w", err)
+		}
+		row := make([]string, len(columns))
+		for i := range d.docs {
+			for c, col := range columns {
+				row[c] = d.cell(i, col)
+			}
+			if err := w.Write(row); err != nil {
+				return fmt.Errorf("failed to write csv: %w", err)
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return fmt.Errorf("failed to write csv: %w", err)
+		}
+	}
+	return f.Close()
+}
+
+// exportCmd writes the data in the background and reports the result as
+// a status message, asking first when the file exists.
+func exportCmd(d exportData, req exportRequest) tea.Cmd {
+	return confirmOverwrite(req.path, func(overwrite bool) tea.Cmd {
+		return func() tea.Msg {
+			if err := d.write(req.path, req.columns, overwrite); err != nil {
+				return statusMsg{err: err}
+			}
+			return statusMsg{text: fmt.Sprintf("exported %d to %s", len(d.docs), req.path)}
+		}
+	})
+}
+
+// confirmOverwrite returns the export of write, which is asked to be
+// confirmed when path exists. write is told whether it may replace the file.
+func confirmOverwrite(path string, write func(overwrite bool) tea.Cmd) tea.Cmd {
+	if _, err := os.Stat(path); err == nil {
+		question := path + " exists, overwrite it?"
+		return func() tea.Msg { return confirmMsg{question: question, yes: write(true)} }
+	}
+	return write(false)
+}
+
+// createExportFile creates path for writing. Without overwrite it fails if
+// the file exists, as one may have been created since it was checked.
+func createExportFile(path string, overwrite bool) (*os.File, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !overwrite {
+		flag |= os.O_EXCL
+	}
+	f, err := os.OpenFile(path, flag, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create export file: %w", err)
+	}
+	return f, nil
+}
+
+type exportRequest struct {
+	path    string
+	columns []string
+}
+
+// exportPrompt asks for an export destination in the form
+// `<file> [column, ...]`, where columns only apply to CSV.
+type exportPrompt struct {
+	input  textinput.Model
+	active bool
+	err    string
+}
+
+func newExportPrompt() exportPrompt {
+	ti := textinput.New()
+	ti.Prompt = "export> "
+	ti.Placeholder = "file.json | file.ndjson | file.csv col1,col2"
+	return exportPrompt{input: ti}
+}
+
+func (p *exportPrompt) Open(value string) tea.Cmd {
+	p.active = true
+	p.err = ""
+	p.input.SetValue(value)
+	p.input.CursorEnd()
+	return p.input.Focus()
+}
+
+func (p *exportPrompt) Close() {
+	p.active = false
+	p.input.Blur()
+}
+
+// Update handles input while the prompt is open, returning a request once
+// it is submitted.
+func (p *exportPrompt) Update(msg tea.Msg) (*exportRequest, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			p.Close()
+			return nil, nil
+		case "enter":
+			path, cols, _ := strings.Cut(strings.TrimSpace(p.input.Value()), " ")
+			if path == "" {
+				p.err = "file name is required"
+				return nil, nil
+			}
+			if _, err := exportFormatFor(path); err != nil {
+				p.err = err.Error()
+				return nil, nil
+			}
+			req := &exportRequest{path: path}
+			for _, c := range strings.Split(cols, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					req.columns = append(req.columns, c)
+				}
+			}
+			p.Close()
+			return req, nil
+		}
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return nil, cmd
+}
+
+func (p *exportPrompt) View() string {
+	if !p.active {
+		return ""
+	}
+	if p.err != "" {
+		return p.input.View() + "\n" + queryErrStyle.Render(p.err)
+	}
+	return p.input.View()
+}
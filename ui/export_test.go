@@ -0,0 +1,39 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportConfirmsOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	if err := os.WriteFile(path, []byte("keep\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	d := exportData{docs: []any{map[string]any{"did": "did:plc:abc"}}}
+
+	msg, ok := exportCmd(d, exportRequest{path: path})().(confirmMsg)
+	if !ok {
+		t.Fatal("existing file was not confirmed")
+	}
+	if data, _ := os.ReadFile(path); string(data) != "keep\n" {
+		t.Fatalf("file changed before confirming: %q", data)
+	}
+	if res := msg.yes().(statusMsg); res.err != nil {
+		t.Fatal(res.err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "{\"did\":\"did:plc:abc\"}\n" {
+		t.Errorf("overwritten file = %q", data)
+	}
+
+	// a new file is written right away, and not replaced if created since
+	path = filepath.Join(t.TempDir(), "new.ndjson")
+	cmd := exportCmd(d, exportRequest{path: path})
+	if err := os.WriteFile(path, []byte("keep\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := cmd().(statusMsg); !ok || res.err == nil {
+		t.Errorf("file created since the check was replaced: %+v", res)
+	}
+}
@@ -7,61 +7,305 @@ import (
 
 	"github.com/bluesky-social/jetstream/pkg/models"
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/treethought/attie/at"
 )
 
 type jetEventSelectedMsg struct {
-	evt *models.Event
+	evt *at.Event
+}
+
+// openEventMsg opens the author's repo, or the live record of a commit event.
+type openEventMsg struct {
+	evt    *at.Event
+	record bool
+}
+
+// filterEventMsg narrows the stream to the DID or collection of an event.
+type filterEventMsg struct {
+	did        string
+	collection string
 }
 
 type JetStreamEventView struct {
 	ContentView
-	evt *models.Event
+	evt *at.Event
+
+	history *recordHistory
+	// showDiff shows the changes of update events instead of the event
+	showDiff bool
+	// diff of the current update event and the number of changes, or why
+	// there is none
+	diff    string
+	changes int
+	diffErr string
+}
+
+func NewJetEventView(preview bool, history *recordHistory) *JetStreamEventView {
+	v := &JetStreamEventView{
+		ContentView: newContentView(preview),
+		history:     history,
+		showDiff:    true,
+	}
+	v.exporter = v.exportData
+	return v
 }
 
-func NewJetEventView(preview bool) *JetStreamEventView {
-	return &JetStreamEventView{ContentView: newContentView(preview)}
+func (v *JetStreamEventView) exportData() (exportData, string) {
+	d := eventsExport([]*at.Event{v.evt})
+	d.single = true
+	return d, fmt.Sprintf("event-%d.json", v.evt.TimeUS)
 }
 
 func (v *JetStreamEventView) buildHeader() string {
 	if v.evt == nil {
 		return ""
 	}
+	var kind, subject string
+	switch {
+	case v.evt.Identity != nil:
+		kind, subject = identityStyle.Render("identity"), identityHandle(v.evt)
+	case v.evt.Account != nil:
+		kind, subject = accountStyle.Render("account"), accountStatus(v.evt)
+	case v.evt.Commit != nil:
+		kind = opStyle.Render(v.evt.Commit.Operation)
+		subject = v.evt.Commit.Collection + "/" + v.evt.Commit.RKey
+		if v.preview {
+			subject = v.evt.Commit.Collection + "  " + dimStyle.Render(v.evt.Commit.RKey)
+		}
+	default:
+		kind = opStyle.Render(v.evt.Kind)
+	}
+	var hdr string
 	if v.preview {
-		return headerStyle.Render(fmt.Sprintf("%s  %s  %s",
-			opStyle.Render(string(v.evt.Commit.Operation)),
-			v.evt.Commit.Collection,
-			dimStyle.Render(v.evt.Commit.RKey),
+		hdr = headerStyle.Render(fmt.Sprintf("%s  %s", kind, subject))
+	} else {
+		t := time.Unix(0, v.evt.TimeUS*int64(time.Microsecond))
+		hdr = headerStyle.Render(fmt.Sprintf("%s  %s  %s  %s",
+			didStyle.Render(v.evt.Did),
+			subject,
+			kind,
+			dimStyle.Render(t.Format("2006-01-02 15:04:05")),
 		))
+		if v.evt.Firehose != nil {
+			hdr += dimStyle.Render(fmt.Sprintf("  #%d", v.evt.Firehose.Seq))
+		}
+	}
+	if d := v.diffLine(); d != "" {
+		hdr = lipgloss.JoinVertical(lipgloss.Left, hdr, d)
+	}
+	if v.evt.Firehose != nil && v.evt.Firehose.Error != "" {
+		hdr = lipgloss.JoinVertical(lipgloss.Left, hdr, queryErrStyle.Render("✗ "+v.evt.Firehose.Error))
+	}
+	if vl := v.verifyLine(); vl != "" {
+		hdr = lipgloss.JoinVertical(lipgloss.Left, hdr, vl)
 	}
-	t := time.Unix(0, v.evt.TimeUS*int64(time.Microsecond))
-	return headerStyle.Render(fmt.Sprintf("%s  %s/%s  %s  %s",
-		didStyle.Render(v.evt.Did),
-		v.evt.Commit.Collection,
-		v.evt.Commit.RKey,
-		opStyle.Render(string(v.evt.Commit.Operation)),
-		dimStyle.Render(t.Format("2006-01-02 15:04:05")),
-	))
+	return hdr
 }
 
-func (v *JetStreamEventView) SetEvent(evt *models.Event) {
+// verifyLine lists the problems found verifying a firehose commit.
+func (v *JetStreamEventView) verifyLine() string {
+	if v.evt.Firehose == nil || v.evt.Firehose.Verify == nil {
+		return ""
+	}
+	ver := v.evt.Firehose.Verify
+	switch ver.Status {
+	case at.VerifyPending:
+		return dimStyle.Render("verifying signature…")
+	case at.VerifyUnverified:
+		return accountStyle.Render("? valid commit, signature not checked") +
+			dimStyle.Render("  ·  the DID did not resolve or too many signatures were pending")
+	case at.VerifyValid:
+		return didStyle.Render("✓ valid commit and signature")
+	}
+	lines := make([]string, 0, len(ver.Errors))
+	for _, e := range ver.Errors {
+		lines = append(lines, queryErrStyle.Render("✗ "+e))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// diffLine summarizes the diff of an update event below the header.
+func (v *JetStreamEventView) diffLine() string {
+	if !isUpdate(v.evt) {
+		return ""
+	}
+	if v.diffErr != "" {
+		return dimStyle.Render(v.diffErr)
+	}
+	toggle := "d shows the event"
+	if !v.showDiff {
+		toggle = "d shows the diff"
+	}
+	return accountStyle.Render(fmt.Sprintf("%d changes since the previous version", v.changes)) +
+		dimStyle.Render("  ·  "+toggle)
+}
+
+func isUpdate(evt *at.Event) bool {
+	return evt != nil && evt.Commit != nil && evt.Commit.Operation == models.CommitOperationUpdate
+}
+
+// buildDiff diffs an update event against the version of its record seen
+// before it.
+func (v *JetStreamEventView) buildDiff() {
+	v.diff, v.changes, v.diffErr = "", 0, ""
+	if !isUpdate(v.evt) {
+		return
+	}
+	prev, ok := v.history.prior(eventURI(v.evt), v.evt.Commit.CID)
+	if !ok {
+		v.diffErr = "previous version not seen, no diff"
+		return
+	}
+	diff, n, err := recordDiff(prev.value, v.evt.Commit.Record)
+	if err != nil {
+		v.diffErr = err.Error()
+		return
+	}
+	v.diff, v.changes = diff, n
+}
+
+// refreshVerify shows the completed verification of the current event, if
+// it is part of the message.
+func (v *JetStreamEventView) refreshVerify(info *at.FirehoseInfo) tea.Cmd {
+	if v.evt != nil && v.evt.Firehose == info {
+		return v.SetEvent(v.evt)
+	}
+	return nil
+}
+
+// toggleDiff switches update events between the diff and the event JSON.
+func (v *JetStreamEventView) toggleDiff() tea.Cmd {
+	v.showDiff = !v.showDiff
+	return v.SetEvent(v.evt)
+}
+
+func (v *JetStreamEventView) SetEvent(evt *at.Event) tea.Cmd {
 	v.evt = evt
 	if evt == nil {
-		v.Set("", "")
-		return
+		return v.Set("", "")
 	}
 	data, err := json.MarshalIndent(evt, "", "  ")
 	if err != nil {
 		data = fmt.Appendf([]byte{}, "error marshaling event: %v", err)
 	}
-	v.Set(v.buildHeader(), string(data))
+	v.buildDiff()
+	v.alt = ""
+	if v.showDiff {
+		v.alt = v.diff
+	}
+	cmd := v.Set(v.buildHeader(), string(data))
+	if v.h > 0 {
+		// the diff line changes the header height
+		v.SetSize(v.w, v.h)
+	}
+	return cmd
 }
 
 func (v *JetStreamEventView) Init() tea.Cmd {
 	return v.initVP()
 }
 func (v *JetStreamEventView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && !v.CapturingInput() && v.evt != nil {
+		switch msg.String() {
+		case "y", "Y", "J":
+			return v, copyEventCmd(msg.String(), v.evt)
+		case "r", "v", "D", "C":
+			return v, eventActionCmd(msg.String(), v.evt)
+		case "d":
+			return v, v.toggleDiff()
+		}
+	}
 	return v, v.updateVP(msg)
 }
+
+func (v *JetStreamEventView) Commands() []command {
+	cmds := append(eventCommands(func() *at.Event { return v.evt }), v.contentCommands("event")...)
+	return append(cmds, command{name: "toggle diff", run: func(string) tea.Cmd { return v.toggleDiff() }})
+}
+
+// eventCommands are the palette commands for the selected event.
+func eventCommands(selected func() *at.Event) []command {
+	copyCmd := func(key string) func(string) tea.Cmd {
+		return func(string) tea.Cmd {
+			if evt := selected(); evt != nil {
+				return copyEventCmd(key, evt)
+			}
+			return nil
+		}
+	}
+	actionCmd := func(key string) func(string) tea.Cmd {
+		return func(string) tea.Cmd {
+			if evt := selected(); evt != nil {
+				return eventActionCmd(key, evt)
+			}
+			return nil
+		}
+	}
+	return []command{
+		{name: "copy uri", run: copyCmd("y")},
+		{name: "copy cid", run: copyCmd("Y")},
+		{name: "copy json", run: copyCmd("J")},
+		{name: "open repo", run: actionCmd("r")},
+		{name: "open live record", run: actionCmd("v")},
+		{name: "jetstream: filter to event did", run: actionCmd("D")},
+		{name: "jetstream: filter to event collection", run: actionCmd("C")},
+	}
+}
+
+// eventActionCmd opens the event's repo (r) or live record (v), or filters
+// the stream to its DID (D) or collection (C).
+func eventActionCmd(key string, evt *at.Event) tea.Cmd {
+	switch key {
+	case "r":
+		return func() tea.Msg { return openEventMsg{evt: evt} }
+	case "v":
+		if evt.Commit == nil {
+			return errCmd(fmt.Errorf("%s event has no record", evt.Kind))
+		}
+		if evt.Commit.Operation == models.CommitOperationDelete {
+			return errCmd(fmt.Errorf("record was deleted"))
+		}
+		return func() tea.Msg { return openEventMsg{evt: evt, record: true} }
+	case "D":
+		return func() tea.Msg { return filterEventMsg{did: evt.Did} }
+	case "C":
+		if evt.Commit == nil {
+			return errCmd(fmt.Errorf("%s event has no collection", evt.Kind))
+		}
+		return func() tea.Msg { return filterEventMsg{collection: evt.Commit.Collection} }
+	}
+	return nil
+}
+
+// eventURI returns the at:// URI of the record a commit event refers to.
+func eventURI(evt *at.Event) string {
+	if evt.Commit == nil {
+		return ""
+	}
+	return fmt.Sprintf("at://%s/%s/%s", evt.Did, evt.Commit.Collection, evt.Commit.RKey)
+}
+
+// copyEventCmd copies the event's record URI (y), record CID (Y) or the
+// event JSON (J).
+func copyEventCmd(key string, evt *at.Event) tea.Cmd {
+	switch key {
+	case "y":
+		return copyCmd("URI", eventURI(evt))
+	case "Y":
+		if evt.Commit == nil {
+			return copyCmd("CID", "")
+		}
+		return copyCmd("CID", evt.Commit.CID)
+	case "J":
+		data, err := json.MarshalIndent(evt, "", "  ")
+		if err != nil {
+			return errCmd(err)
+		}
+		return copyCmd("JSON", string(data))
+	}
+	return nil
+}
 func (v *JetStreamEventView) View() string {
 	return v.renderVP()
 }
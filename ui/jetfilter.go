@@ -0,0 +1,132 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bluesky-social/jetstream/pkg/models"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/treethought/attie/at"
+)
+
+// editJetStreamFilterMsg asks the App to resolve handles and apply new
+// stream filters.
+type editJetStreamFilterMsg struct {
+	collections []string
+	ids         []string
+}
+
+// eventKinds are the kinds of events JetStream sends, and the firehose
+// kinds. Identity and account events are sent regardless of the collection
+// filter, so kinds are filtered locally.
+var eventKinds = []string{models.EventKindCommit, models.EventKindIdentity, models.EventKindAccount, at.EventKindSync, at.EventKindError}
+
+func validateKinds(kinds []string) error {
+	for _, k := range kinds {
+		if !slices.Contains(eventKinds, k) {
+			return fmt.Errorf("unknown event kind %q, expected one of %s", k, strings.Join(eventKinds, ", "))
+		}
+	}
+	return nil
+}
+
+// streamFilters is the parsed input of the filter prompt.
+type streamFilters struct {
+	editJetStreamFilterMsg
+	kinds []string
+}
+
+// filterPrompt edits the stream filters as a list of collections, NSID
+// prefixes like app.bsky.graph.*, DIDs, @handles and kind:<kind>.
+type filterPrompt struct {
+	input  textinput.Model
+	active bool
+	err    string
+}
+
+func newFilterPrompt() filterPrompt {
+	ti := textinput.New()
+	ti.Prompt = "filter> "
+	ti.Placeholder = "app.bsky.feed.post app.bsky.graph.* did:plc:... @handle"
+	return filterPrompt{input: ti}
+}
+
+func (p *filterPrompt) Open(cxs, dids, kinds []string) tea.Cmd {
+	p.active = true
+	p.err = ""
+	toks := append(append([]string{}, cxs...), dids...)
+	for _, k := range kinds {
+		toks = append(toks, "kind:"+k)
+	}
+	p.input.SetValue(strings.Join(toks, " "))
+	p.input.CursorEnd()
+	return p.input.Focus()
+}
+
+func (p *filterPrompt) Close() {
+	p.active = false
+	p.input.Blur()
+}
+
+// Update handles input while the prompt is open, returning the filters once
+// they are submitted.
+func (p *filterPrompt) Update(msg tea.Msg) (*streamFilters, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			p.Close()
+			return nil, nil
+		case "enter":
+			f, err := parseStreamFilters(p.input.Value())
+			if err != nil {
+				p.err = err.Error()
+				return nil, nil
+			}
+			p.Close()
+			return f, nil
+		}
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return nil, cmd
+}
+
+func (p *filterPrompt) View() string {
+	if !p.active {
+		return ""
+	}
+	if p.err != "" {
+		return p.input.View() + "\n" + queryErrStyle.Render(p.err)
+	}
+	return p.input.View()
+}
+
+// parseStreamFilters splits space or comma separated filters into
+// collections, DIDs or @handles and event kinds. Handles are resolved by the
+// App.
+func parseStreamFilters(s string) (*streamFilters, error) {
+	f := &streamFilters{}
+	var dids []string
+	for _, tok := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		switch {
+		case strings.HasPrefix(tok, "kind:"):
+			f.kinds = append(f.kinds, strings.TrimPrefix(tok, "kind:"))
+		case strings.HasPrefix(tok, "did:"):
+			dids = append(dids, tok)
+			f.ids = append(f.ids, tok)
+		case strings.HasPrefix(tok, "@"):
+			f.ids = append(f.ids, strings.TrimPrefix(tok, "@"))
+		default:
+			f.collections = append(f.collections, tok)
+		}
+	}
+	if err := at.ValidateFilters(f.collections, dids); err != nil {
+		return nil, err
+	}
+	if err := validateKinds(f.kinds); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -2,49 +2,250 @@ package ui
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/bluesky-social/jetstream/pkg/models"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/treethought/attie/at"
+	"github.com/treethought/attie/config"
 )
 
 var (
-	opStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
-	didStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	opStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
+	didStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	identityStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	accountStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
 )
 
+// eventItem is a list item for a JetStream event of any kind.
+type eventItem interface {
+	list.DefaultItem
+	event() *at.Event
+}
+
+// newEventItem wraps evt with its filter value, computed once as the list
+// filters every item each time it is re-filtered.
+func newEventItem(evt *at.Event) eventItem {
+	switch {
+	case evt.Identity != nil:
+		return identityEventItem{evt: evt, filter: eventFilterValue(evt.Did+" identity", evt)}
+	case evt.Account != nil:
+		return accountEventItem{evt: evt, filter: eventFilterValue(evt.Did+" account", evt)}
+	}
+	item := jetEventItem{evt: evt, filter: eventFilterValue(evt.Did, evt)}
+	if evt.Commit != nil {
+		item.filter = eventFilterValue(evt.Did+" "+evt.Commit.Operation+" "+evt.Commit.Collection, evt)
+	}
+	if evt.Firehose != nil {
+		item.verify = evt.Firehose.Verify
+	}
+	return item
+}
+
+// eventFilterValue pairs a label with the event JSON so the list filter can
+// match fields like did, kind, commit.collection or commit.record.text, see
+// fieldQueryFilter.
+func eventFilterValue(label string, evt *at.Event) string {
+	data, err := json.Marshal(evt)
+	if err != nil {
+		return label
+	}
+	return label + filterValueSep + string(data)
+}
+
+func eventDescription(evt *at.Event) string {
+	t := time.Unix(0, evt.TimeUS*int64(time.Microsecond))
+	desc := fmt.Sprintf("%s - %s", didStyle.Render(evt.Did), t.Format("2006-01-02 15:04:05"))
+	if evt.Firehose != nil {
+		desc += dimStyle.Render(fmt.Sprintf(" #%d", evt.Firehose.Seq))
+	}
+	return desc
+}
+
 type jetEventItem struct {
-	evt *models.Event
+	evt    *at.Event
+	filter string
+	// verification the filter value was computed with
+	verify *at.Verification
 }
 
-func (j jetEventItem) FilterValue() string {
-	return ""
+// stale reports whether the verification of a firehose event changed since
+// its filter value was computed.
+func (j jetEventItem) stale() bool {
+	return j.evt.Firehose != nil && j.evt.Firehose.Verify != j.verify
 }
+
+func (j jetEventItem) event() *at.Event    { return j.evt }
+func (j jetEventItem) FilterValue() string { return j.filter }
 func (j jetEventItem) Title() string {
+	if j.evt.Commit == nil {
+		return opStyle.Render(j.evt.Kind) + firehoseMark(j.evt)
+	}
 	return fmt.Sprintf("%s %s %s",
 		opStyle.Render(j.evt.Commit.Operation), j.evt.Commit.Collection, dimStyle.Render(j.evt.Commit.RKey),
-	)
+	) + firehoseMark(j.evt)
+}
+
+// firehoseMark flags firehose messages that failed to decode or commits
+// that failed verification with the first problem found, and marks the
+// commits checked or being checked.
+func firehoseMark(evt *at.Event) string {
+	if evt.Firehose == nil {
+		return ""
+	}
+	if evt.Firehose.Error != "" {
+		return queryErrStyle.Render(" ✗ " + evt.Firehose.Error)
+	}
+	if evt.Firehose.Verify == nil {
+		return ""
+	}
+	ver := evt.Firehose.Verify
+	switch ver.Status {
+	case at.VerifyInvalid:
+		return queryErrStyle.Render(" ✗ " + ver.Errors[0])
+	case at.VerifyPending:
+		return dimStyle.Render(" …")
+	case at.VerifyUnverified:
+		return accountStyle.Render(" ? signature not checked")
+	}
+	return didStyle.Render(" ✓")
 }
 
 func (j jetEventItem) Description() string {
-	t := time.Unix(0, j.evt.TimeUS*int64(time.Microsecond))
-	return fmt.Sprintf("%s - %s", didStyle.Render(j.evt.Did), t.Format("2006-01-02 15:04:05"))
+	return eventDescription(j.evt)
+}
+
+// identityEventItem is a handle or DID document change.
+type identityEventItem struct {
+	evt    *at.Event
+	filter string
+}
+
+func (j identityEventItem) event() *at.Event    { return j.evt }
+func (j identityEventItem) FilterValue() string { return j.filter }
+func (j identityEventItem) Title() string {
+	return identityStyle.Render("identity") + " " + identityHandle(j.evt)
+}
+func (j identityEventItem) Description() string {
+	return eventDescription(j.evt)
+}
+
+func identityHandle(evt *at.Event) string {
+	if evt.Identity.Handle == nil {
+		return dimStyle.Render("no handle")
+	}
+	return *evt.Identity.Handle
+}
+
+// accountEventItem is a change of account status, such as a deactivation
+// or takedown.
+type accountEventItem struct {
+	evt    *at.Event
+	filter string
+}
+
+func (j accountEventItem) event() *at.Event    { return j.evt }
+func (j accountEventItem) FilterValue() string { return j.filter }
+func (j accountEventItem) Title() string {
+	return accountStyle.Render("account") + " " + accountStatus(j.evt)
+}
+func (j accountEventItem) Description() string {
+	return eventDescription(j.evt)
+}
+
+func accountStatus(evt *at.Event) string {
+	if evt.Account.Active {
+		return "active"
+	}
+	if evt.Account.Status != nil {
+		return queryErrStyle.Render(*evt.Account.Status)
+	}
+	return queryErrStyle.Render("inactive")
 }
 
+// eventMsg and jetStreamErrorMsg carry the view whose client produced them,
+// so they reach it while another tab is shown, and the run they came from,
+// so those of a stopped run are dropped.
 type eventMsg struct {
-	evt *models.Event
+	src    *JetStreamView
+	stream *at.Stream
+	evt    *at.Event
+	// set by the App when the stream is on screen
+	visible bool
 }
 
 type jetStreamErrorMsg struct {
-	err error
+	src    *JetStreamView
+	stream *at.Stream
+	err    error
+	// evts were read before the stream ended but not yet received
+	evts []*at.Event
+	// set by the App when the stream is on screen
+	visible bool
 }
 
+type jetStreamConnectedMsg struct {
+	src    *JetStreamView
+	stream *at.Stream
+}
+
+// jetFilterMatchesMsg carries the result of filtering a stream's list back
+// to it, as the App hands other filter results to the active view.
+type jetFilterMatchesMsg struct {
+	src     *JetStreamView
+	matches list.FilterMatchesMsg
+}
+
+// refilterMsg adds the events queued while the list is filtered.
+type refilterMsg struct {
+	src *JetStreamView
+}
+
+// refilterInterval is how often a filtered list takes new events, as
+// each time the whole buffer is filtered again.
+const refilterInterval = 500 * time.Millisecond
+
+// verifiedMsg completes the verification of a firehose commit once its
+// signature is checked.
+type verifiedMsg struct {
+	src    *JetStreamView
+	stream *at.Stream
+	res    at.SignatureResult
+}
+
+// jetStreamReconnectMsg retries a dropped connection, unless the stream was
+// stopped or restarted since it was scheduled.
+type jetStreamReconnectMsg struct {
+	src *JetStreamView
+	gen int
+}
+
+type connState int
+
+const (
+	connStopped connState = iota
+	connConnecting
+	connLive
+	connReconnecting
+	connFailed
+	// reading events from a recording instead of the network
+	connReplaying
+)
+
+const (
+	reconnectBaseDelay   = time.Second
+	reconnectMaxDelay    = time.Minute
+	maxReconnectAttempts = 10
+)
+
 type session struct {
 	lastCursor  *int64
 	collections []string
@@ -57,11 +258,60 @@ type JetStreamView struct {
 	jc      *at.JetStreamClient
 	ctx     context.Context
 	cancel  context.CancelFunc
+	// stream is the current run, nil once stopped
+	stream  *at.Stream
 	session session
 	w, h    int
+	export  exportPrompt
+	filter  filterPrompt
+	replay  replayPrompt
+	// events received since the stream was last shown
+	unread int
+	// kinds of events to show, all when empty
+	kinds []string
+
+	state    connState
+	attempts int
+	retryAt  time.Time
+	// whether the current run has received an event, which shows the
+	// connection works rather than just accepting and closing
+	delivered bool
+	// times the connection dropped after delivering events
+	gaps int
+	// bumped on every start and stop to discard stale reconnects
+	gen int
+
+	cfg *config.Config
+	// buffer holds the received events of all kinds, oldest first; the
+	// list shows those of the wanted kinds
+	buffer []*at.Event
+	// events removed from the front of the full buffer
+	evicted int
+
+	stats     *streamStats
+	showStats bool
+	// height of the list below the header
+	bodyH int
+
+	// paused freezes the list, holding new events in pending
+	paused  bool
+	pending []*at.Event
+
+	// docs caches the decoded documents of the list filter
+	docs *filterDocs
+	// events held while a filter is applied, added on the next refilter
+	queued      []*at.Event
+	refiltering bool
+	// set when filter values of the list are outdated by verifications
+	verifyStale bool
+
+	// recorder writes the raw stream to disk while set
+	recorder *at.Recorder
+	// speed of the recording being replayed
+	replaySpeed float64
 }
 
-func NewJetStreamView(jc *at.JetStreamClient) *JetStreamView {
+func NewJetStreamView(cfg *config.Config, jc *at.JetStreamClient, history *recordHistory) *JetStreamView {
 	del := list.DefaultDelegate{
 		ShowDescription: true,
 		Styles:          list.NewDefaultItemStyles(),
@@ -69,44 +319,415 @@ func NewJetStreamView(jc *at.JetStreamClient) *JetStreamView {
 	del.SetHeight(2)
 
 	l := list.New(nil, del, 80, 20)
+	freeListKeys(&l.KeyMap)
+	freeListD(&l.KeyMap)
 	l.SetShowTitle(false)
 	l.SetShowStatusBar(false)
-	l.SetFilteringEnabled(false)
+	l.SetFilteringEnabled(true)
+	docs := &filterDocs{}
+	l.Filter = docs.filter
+	jc.SetURL(cfg.JetStream.URL)
+	jc.SetBlocking(cfg.JetStream.Blocking())
+	jc.SetCompression(cfg.JetStream.Compressed())
+	jc.SetVerify(cfg.JetStream.Verified())
 	return &JetStreamView{
 		list:    l,
-		preview: NewJetEventView(true),
+		preview: NewJetEventView(true, history),
 		jc:      jc,
+		cfg:     cfg,
+		stats:   newStreamStats(),
+		export:  newExportPrompt(),
+		filter:  newFilterPrompt(),
+		replay:  newReplayPrompt(),
+		docs:    docs,
 	}
 }
 
+// Listen waits for the next message of the current run.
 func (m *JetStreamView) Listen() tea.Cmd {
+	s := m.stream
+	if s == nil {
+		return nil
+	}
 	return func() tea.Msg {
 		select {
-		case err := <-m.jc.Err():
+		case err := <-s.Err():
 			slog.Error("JetStream client error", "error", err)
-			return jetStreamErrorMsg{err: err}
-		case evt := <-m.jc.Out():
+			// the run has ended, so this takes all its events, which are
+			// received before the error
+			msg := jetStreamErrorMsg{src: m, stream: s, err: err}
+			for {
+				select {
+				case evt := <-s.Out():
+					msg.evts = append(msg.evts, evt)
+					continue
+				default:
+				}
+				return msg
+			}
+		case evt := <-s.Out():
 			slog.Info("Received JetStream event", "did", evt.Did, "kind", evt.Kind)
-			return eventMsg{evt: evt}
+			return eventMsg{src: m, stream: s, evt: evt}
+		case <-s.Connected():
+			return jetStreamConnectedMsg{src: m, stream: s}
+		case res := <-m.jc.Verified():
+			return verifiedMsg{src: m, stream: s, res: res}
 		}
 	}
 }
 
-func (m *JetStreamView) AddEvent(evt *models.Event) tea.Cmd {
-	m.session.lastCursor = &evt.TimeUS
-	item := jetEventItem{evt: evt}
-	return m.list.InsertItem(0, item)
+// receive counts an event read from the stream and adds it.
+func (m *JetStreamView) receive(evt *at.Event, visible bool) tea.Cmd {
+	if !m.delivered {
+		// backing off starts over once a connection delivers
+		m.delivered = true
+		m.attempts = 0
+	}
+	now := time.Now()
+	m.stats.add(evt, now)
+	received, decoded := m.jc.Bytes()
+	m.stats.addBytes(received, decoded, now)
+	return m.AddEvent(evt, visible)
 }
 
-func (m *JetStreamView) Running() bool {
-	return m.ctx != nil
+// AddEvent buffers the event and prepends it to the list if its kind is
+// shown, keeping the selection on the same event unless the newest one is
+// selected.
+func (m *JetStreamView) AddEvent(evt *at.Event, visible bool) tea.Cmd {
+	m.advanceCursor(evt)
+	m.buffer = append(m.buffer, evt)
+	evict := m.evict()
+	if !m.wantsKind(evt.Kind) {
+		return evict
+	}
+	if !visible {
+		m.unread++
+	}
+	if m.paused {
+		m.pending = append(m.pending, evt)
+		return evict
+	}
+	if m.list.FilterState() != list.Unfiltered {
+		m.queued = append(m.queued, evt)
+		return tea.Batch(m.scheduleRefilter(), evict)
+	}
+	follow := visible && m.list.Index() == 0
+	cmd := m.list.InsertItem(0, newEventItem(evt))
+	if !follow && len(m.list.Items()) > 1 {
+		m.list.Select(m.list.Index() + 1)
+	}
+	return tea.Batch(m.tagFilter(cmd), evict)
 }
-func (m *JetStreamView) Clear() tea.Cmd {
+
+func (m *JetStreamView) scheduleRefilter() tea.Cmd {
+	if m.refiltering {
+		return nil
+	}
+	m.refiltering = true
+	return tea.Tick(refilterInterval, func(time.Time) tea.Msg { return refilterMsg{src: m} })
+}
+
+// refilter adds the events queued while the list is filtered in one go,
+// and updates the filter values of events verified since.
+func (m *JetStreamView) refilter() tea.Cmd {
+	m.refiltering = false
+	queued := m.queued
+	m.queued = nil
+	if m.paused {
+		m.pending = append(m.pending, queued...)
+		queued = nil
+	}
+	if len(queued) == 0 && !m.verifyStale {
+		return nil
+	}
+	return m.prepend(queued)
+}
+
+// refreshVerified updates the filter values of the events of a verified
+// message. A filtered list is refreshed on the next refilter, to filter it
+// once for all messages verified meanwhile.
+func (m *JetStreamView) refreshVerified(info *at.FirehoseInfo) tea.Cmd {
+	if m.list.FilterState() != list.Unfiltered {
+		m.verifyStale = true
+		return m.scheduleRefilter()
+	}
+	for i, item := range m.list.Items() {
+		if j, ok := item.(jetEventItem); ok && j.evt.Firehose == info {
+			m.list.SetItem(i, newEventItem(j.evt))
+		}
+	}
+	return nil
+}
+
+// prepend adds events, oldest first, to the front of the list, keeping the
+// selection on the same event. Items verified since their filter value was
+// computed are updated.
+func (m *JetStreamView) prepend(evts []*at.Event) tea.Cmd {
+	if len(evts) == 0 && !m.verifyStale {
+		return nil
+	}
+	items := make([]list.Item, 0, len(evts)+len(m.list.Items()))
+	for i := len(evts) - 1; i >= 0; i-- {
+		items = append(items, newEventItem(evts[i]))
+	}
+	for _, item := range m.list.Items() {
+		if j, ok := item.(jetEventItem); ok && m.verifyStale && j.stale() {
+			item = newEventItem(j.evt)
+		}
+		items = append(items, item)
+	}
+	m.verifyStale = false
+	if len(items) == 0 {
+		return nil
+	}
+	idx := m.list.Index()
+	// the selection of a filtered list follows the filtered items
+	if idx > 0 && m.list.FilterState() == list.Unfiltered {
+		idx += len(evts)
+	}
+	cmd := m.list.SetItems(items)
+	m.list.Select(min(idx, len(items)-1))
+	return m.tagFilter(cmd)
+}
+
+// showKinds rebuilds the list from the buffered events of the wanted kinds,
+// keeping the selection on the same event while it is shown. Events held
+// while paused or filtered are shown by the rebuild.
+func (m *JetStreamView) showKinds() tea.Cmd {
+	selected := m.selectedEvent()
+	shown := make(map[*at.Event]list.Item, len(m.list.Items()))
+	for _, item := range m.list.Items() {
+		if e, ok := item.(eventItem); ok {
+			shown[e.event()] = item
+		}
+	}
+	m.pending = nil
+	m.queued = nil
+	items := make([]list.Item, 0, len(m.buffer))
+	idx := 0
+	for i := len(m.buffer) - 1; i >= 0; i-- {
+		evt := m.buffer[i]
+		if !m.wantsKind(evt.Kind) {
+			continue
+		}
+		if evt == selected {
+			idx = len(items)
+		}
+		item, ok := shown[evt]
+		if !ok {
+			item = newEventItem(evt)
+		}
+		items = append(items, item)
+	}
+	cmd := m.list.SetItems(items)
+	// the selection of a filtered list follows the filtered items
+	if m.list.FilterState() == list.Unfiltered {
+		m.list.Select(idx)
+	}
+	return m.tagFilter(cmd)
+}
+
+// tagFilter routes the filter results of the list's commands back to the
+// stream, wherever the App is.
+func (m *JetStreamView) tagFilter(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
 	return func() tea.Msg {
-		m.session = session{}
-		m.preview.SetEvent(nil)
-		return m.list.SetItems(nil)
+		switch msg := cmd().(type) {
+		case list.FilterMatchesMsg:
+			return jetFilterMatchesMsg{src: m, matches: msg}
+		case tea.BatchMsg:
+			cmds := make(tea.BatchMsg, len(msg))
+			for i, c := range msg {
+				cmds[i] = m.tagFilter(c)
+			}
+			return cmds
+		default:
+			return msg
+		}
+	}
+}
+
+// advanceCursor resumes the stream after evt, unless its position is
+// unknown.
+func (m *JetStreamView) advanceCursor(evt *at.Event) {
+	if cursor := evt.Cursor(); cursor > 0 {
+		m.session.lastCursor = &cursor
+	}
+}
+
+// togglePause freezes the list, or adds the events received while paused.
+func (m *JetStreamView) togglePause() tea.Cmd {
+	m.paused = !m.paused
+	if m.paused {
+		return nil
+	}
+	pending := m.pending
+	m.pending = nil
+	return m.prepend(pending)
+}
+
+// Back clears an applied filter.
+func (m *JetStreamView) Back() bool {
+	if m.list.FilterState() == list.Unfiltered {
+		return false
+	}
+	m.list.ResetFilter()
+	return true
+}
+
+// visibleEvents returns the events matching the list filter, oldest first.
+func (m *JetStreamView) visibleEvents() []*at.Event {
+	items := m.list.VisibleItems()
+	evts := make([]*at.Event, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		if item, ok := items[i].(eventItem); ok {
+			evts = append(evts, item.event())
+		}
+	}
+	return evts
+}
+
+// evictBatch is the share of the buffer size the list may grow beyond it
+// before the oldest events are evicted, so that they go in batches rather
+// than one by one, as each eviction copies the list and refilters it.
+const evictBatch = 10 // percent
+
+// evict drops the oldest events beyond the configured buffer size once the
+// buffer holds a batch more.
+func (m *JetStreamView) evict() tea.Cmd {
+	size := m.cfg.JetStream.Size()
+	if len(m.buffer) <= size+size*evictBatch/100 {
+		return nil
+	}
+	return m.trim()
+}
+
+// trim drops the oldest events beyond the configured buffer size, from the
+// buffer and from the events shown or held.
+func (m *JetStreamView) trim() tea.Cmd {
+	n := len(m.buffer) - m.cfg.JetStream.Size()
+	if n <= 0 {
+		return nil
+	}
+	evicted := make(map[*at.Event]bool, n)
+	for _, evt := range m.buffer[:n] {
+		evicted[evt] = true
+	}
+	// copied, so the evicted events are released
+	m.buffer = slices.Clone(m.buffer[n:])
+	m.evicted += n
+	isEvicted := func(evt *at.Event) bool { return evicted[evt] }
+	m.pending = slices.DeleteFunc(m.pending, isEvicted)
+	m.queued = slices.DeleteFunc(m.queued, isEvicted)
+
+	// the list is newest first, so its evicted events are at the end
+	items := m.list.Items()
+	keep := len(items)
+	for keep > 0 {
+		if e, ok := items[keep-1].(eventItem); !ok || !evicted[e.event()] {
+			break
+		}
+		keep--
+	}
+	if keep == len(items) {
+		return nil
+	}
+	cmd := m.list.SetItems(slices.Clone(items[:keep]))
+	if keep > 0 && m.list.Index() >= keep {
+		m.list.Select(keep - 1)
+	}
+	return m.tagFilter(cmd)
+}
+
+// SetBufferSize changes how many events are kept and saves it to the config.
+// The returned command refilters the list if events were evicted.
+func (m *JetStreamView) SetBufferSize(n int) (tea.Cmd, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("buffer size must be positive")
+	}
+	m.cfg.JetStream.BufferSize = n
+	return m.trim(), m.cfg.Save()
+}
+
+// SetOverflow chooses whether to drop or block when events arrive faster
+// than they are shown, and saves it to the config.
+func (m *JetStreamView) SetOverflow(policy string) error {
+	if policy != config.OverflowDrop && policy != config.OverflowBlock {
+		return fmt.Errorf("overflow policy must be %s or %s", config.OverflowDrop, config.OverflowBlock)
+	}
+	m.cfg.JetStream.Overflow = policy
+	m.jc.SetBlocking(m.cfg.JetStream.Blocking())
+	return m.cfg.Save()
+}
+
+// SetCompression chooses whether to request zstd compressed messages and
+// saves it to the config. A live stream reconnects to apply it, keeping its
+// events.
+func (m *JetStreamView) SetCompression(compress bool) tea.Cmd {
+	m.cfg.JetStream.DisableCompression = !compress
+	m.jc.SetCompression(compress)
+	if err := m.cfg.Save(); err != nil {
+		return errCmd(err)
+	}
+	if m.ctx != nil && m.state != connReplaying {
+		m.Stop()
+		return m.Resume()
+	}
+	return nil
+}
+
+// SetVerify chooses whether firehose commits are verified and saves it to
+// the config. It applies to the commits received from then on.
+func (m *JetStreamView) SetVerify(verify bool) error {
+	m.cfg.JetStream.DisableVerification = !verify
+	m.jc.SetVerify(verify)
+	return m.cfg.Save()
+}
+
+// SetKinds limits the events shown to the given kinds. Events of all kinds
+// stay buffered, so those of a kind shown again reappear.
+func (m *JetStreamView) SetKinds(kinds []string) (tea.Cmd, error) {
+	if err := validateKinds(kinds); err != nil {
+		return nil, err
 	}
+	m.kinds = kinds
+	return m.showKinds(), nil
+}
+
+func (m *JetStreamView) wantsKind(kind string) bool {
+	return len(m.kinds) == 0 || slices.Contains(m.kinds, kind)
+}
+
+// Unread returns the number of events received while the stream was hidden.
+func (m *JetStreamView) Unread() int {
+	return m.unread
+}
+
+// MarkRead resets the unread count once the stream is shown.
+func (m *JetStreamView) MarkRead() {
+	m.unread = 0
+}
+
+// Running reports whether the stream is connected or trying to connect.
+func (m *JetStreamView) Running() bool {
+	return m.ctx != nil || m.state == connReconnecting
+}
+func (m *JetStreamView) Clear() tea.Cmd {
+	m.session = session{}
+	m.unread = 0
+	m.gaps = 0
+	m.buffer = nil
+	m.evicted = 0
+	m.pending = nil
+	m.queued = nil
+	m.stats = newStreamStats()
+	m.jc.ResetDropped()
+	m.jc.ResetBytes()
+	m.jc.ResetVerifications()
+	m.preview.SetEvent(nil)
+	return m.tagFilter(m.list.SetItems(nil))
 }
 
 func (m *JetStreamView) Start(cxs, dids []string, cursor *int64) tea.Cmd {
@@ -119,20 +740,301 @@ func (m *JetStreamView) Start(cxs, dids []string, cursor *int64) tea.Cmd {
 		collections: cxs,
 		dids:        dids,
 	}
+	m.gen++
+	if m.state != connReconnecting {
+		m.state = connConnecting
+		m.attempts = 0
+	}
+	m.delivered = false
 	m.ctx, m.cancel = context.WithCancel(context.Background())
 	slog.Info("Starting JetStream client", "collections", cxs, "dids", dids, "cursor", cursor)
-	go m.jc.Start(m.ctx, cxs, dids, cursor)
+	m.stream = m.jc.Start(m.ctx, cxs, dids, cursor)
 	return m.Listen()
 }
+
+// SetFilters updates the filters of a running stream in place, keeping its
+// cursor and events, or starts a new stream with them.
+func (m *JetStreamView) SetFilters(cxs, dids []string) tea.Cmd {
+	if !m.Running() {
+		m.Clear()
+		return m.Start(cxs, dids, nil)
+	}
+	if m.state == connReplaying {
+		return errCmd(errors.New("filters cannot change while replaying a recording"))
+	}
+	if m.ctx == nil {
+		// applied when the pending reconnect resumes the stream
+		m.session.collections = cxs
+		m.session.dids = dids
+		return statusCmd("jetstream filters updated")
+	}
+	if err := m.jc.UpdateOptions(cxs, dids); err != nil {
+		return errCmd(err)
+	}
+	m.session.collections = cxs
+	m.session.dids = dids
+	return statusCmd("jetstream filters updated")
+}
+
+// Replay restarts the stream from cursor with the same filters. The firehose
+// replays from a sequence number instead of a time.
+func (m *JetStreamView) Replay(cursor int64) tea.Cmd {
+	if m.firehose() && cursor > maxFirehoseSeq {
+		return errCmd(errors.New("the firehose replays from a sequence number, not a time"))
+	}
+	if !m.firehose() && cursor < minJetStreamCursor {
+		return errCmd(fmt.Errorf("cursor %d is before JetStream existed, use unix microseconds, a timestamp or a duration like 15m ago", cursor))
+	}
+	cxs, dids := m.session.collections, m.session.dids
+	m.Stop()
+	m.Clear()
+	return tea.Batch(
+		m.Start(cxs, dids, &cursor),
+		statusCmd("replaying from "+m.formatCursor(cursor)),
+	)
+}
+
+// maxFirehoseSeq tells firehose sequence numbers from microsecond times,
+// which are far larger.
+const maxFirehoseSeq = 1 << 40
+
+// minJetStreamCursor is 2024-01-01 in unix microseconds. JetStream started
+// later, so smaller cursors are mistakes, such as seconds, milliseconds or a
+// firehose sequence number.
+const minJetStreamCursor = 1_704_067_200_000_000
+
+func (m *JetStreamView) firehose() bool {
+	return m.jc.Firehose() != ""
+}
+
+// Firehose switches the stream to the subscribeRepos endpoint of a relay or
+// PDS, keeping the filters, which are applied to the events received.
+func (m *JetStreamView) Firehose(host string) tea.Cmd {
+	u, err := at.FirehoseURL(host)
+	if err != nil {
+		return errCmd(err)
+	}
+	return m.switchSource(u, "connecting to "+u)
+}
+
+// JetStream switches the stream back from the firehose to JetStream.
+func (m *JetStreamView) JetStream() tea.Cmd {
+	return m.switchSource("", "connecting to jetstream")
+}
+
+func (m *JetStreamView) switchSource(u, status string) tea.Cmd {
+	cxs, dids := m.session.collections, m.session.dids
+	m.Stop()
+	m.Clear()
+	m.jc.SetFirehose(u)
+	return tea.Batch(m.Start(cxs, dids, nil), statusCmd(status))
+}
+
+// ReplayFile replaces the events with those of a recording, read at speed
+// times their original pace.
+func (m *JetStreamView) ReplayFile(path string, speed float64) tea.Cmd {
+	m.Stop()
+	m.Clear()
+	m.state = connReplaying
+	m.replaySpeed = speed
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	slog.Info("Replaying JetStream recording", "path", path, "speed", speed)
+	m.stream = m.jc.Replay(m.ctx, path, speed)
+	return tea.Batch(m.Listen(), statusCmd("replaying "+path))
+}
+
+// Record writes the raw stream to path, zstd compressed when it ends in
+// ".zst", until StopRecording.
+func (m *JetStreamView) Record(path string) tea.Cmd {
+	if m.recorder != nil {
+		m.StopRecording()
+	}
+	if path == "" {
+		path = fmt.Sprintf("jetstream-%s.jsonl.zst", time.Now().Format("20060102-150405"))
+	}
+	rec, err := at.NewRecorder(path)
+	if err != nil {
+		return errCmd(err)
+	}
+	m.recorder = rec
+	m.jc.SetRecorder(rec)
+	m.SetSize(m.w, m.h)
+	return statusCmd("recording to " + path)
+}
+
+// StopRecording closes the recording, if any.
+func (m *JetStreamView) StopRecording() tea.Cmd {
+	if m.recorder == nil {
+		return nil
+	}
+	rec := m.recorder
+	m.jc.SetRecorder(nil)
+	m.recorder = nil
+	m.SetSize(m.w, m.h)
+	if err := rec.Close(); err != nil {
+		return errCmd(fmt.Errorf("failed to close recording: %w", err))
+	}
+	return statusCmd(fmt.Sprintf("recorded %d events to %s", rec.Count(), rec.Path()))
+}
+
+func (m *JetStreamView) toggleRecording() tea.Cmd {
+	if m.recorder != nil {
+		return m.StopRecording()
+	}
+	return m.Record("")
+}
+
+// Resume reconnects a stopped stream after the last event it received,
+// keeping its events.
+func (m *JetStreamView) Resume() tea.Cmd {
+	var cursor *int64
+	if m.session.lastCursor != nil {
+		next := *m.session.lastCursor
+		if !m.firehose() {
+			// JetStream includes the event at the cursor, the firehose
+			// starts after it
+			next++
+		}
+		cursor = &next
+	}
+	return m.Start(m.session.collections, m.session.dids, cursor)
+}
+
 func (m *JetStreamView) Stop() tea.Cmd {
+	m.gen++
+	m.state = connStopped
+	m.disconnect()
+	return nil
+}
+
+func (m *JetStreamView) disconnect() {
 	if m.cancel != nil {
 		slog.Info("Stopping JetStream client")
 		m.cancel()
 		m.ctx = nil
 	}
+	m.stream = nil
+}
+
+// reconnect schedules the next attempt to resume a dropped stream, backing
+// off exponentially, and gives up after maxReconnectAttempts.
+func (m *JetStreamView) reconnect(err error) tea.Cmd {
+	m.disconnect()
+	if m.delivered {
+		m.gaps++
+	}
+	if m.attempts >= maxReconnectAttempts {
+		m.state = connFailed
+		return errCmd(fmt.Errorf("jetstream failed after %d attempts: %w", m.attempts, err))
+	}
+	delay := min(reconnectBaseDelay<<m.attempts, reconnectMaxDelay)
+	m.attempts++
+	m.state = connReconnecting
+	m.retryAt = time.Now().Add(delay)
+	gen := m.gen
+	return tea.Batch(
+		tea.Tick(delay, func(time.Time) tea.Msg { return jetStreamReconnectMsg{src: m, gen: gen} }),
+		errCmd(fmt.Errorf("jetstream disconnected, reconnecting in %s: %w", delay, err)),
+	)
+}
+
+// Events returns the buffered events of all kinds, oldest first.
+func (m *JetStreamView) Events() []*at.Event {
+	return slices.Clone(m.buffer)
+}
+
+func (m *JetStreamView) selectedEvent() *at.Event {
+	if item, ok := m.list.SelectedItem().(eventItem); ok {
+		return item.event()
+	}
 	return nil
 }
 
+func (m *JetStreamView) Commands() []command {
+	return append(eventCommands(m.selectedEvent),
+		command{name: "export events", run: func(string) tea.Cmd { return m.openExport() }},
+		command{name: "jetstream: edit filters", run: func(string) tea.Cmd { return m.openFilter() }},
+		command{name: "jetstream: toggle stats", run: func(string) tea.Cmd { return m.toggleStats() }},
+		command{name: "jetstream: pause", run: func(string) tea.Cmd { return m.togglePause() }},
+		command{name: "toggle diff", run: func(string) tea.Cmd { return m.preview.toggleDiff() }},
+		command{name: "jetstream: stop recording", run: func(string) tea.Cmd { return m.StopRecording() }},
+		command{name: "jetstream: buffer size", arg: fmt.Sprintf("events to keep (now %d)", m.cfg.JetStream.Size()), run: func(arg string) tea.Cmd {
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				return errCmd(fmt.Errorf("invalid buffer size %q", arg))
+			}
+			cmd, err := m.SetBufferSize(n)
+			if err != nil {
+				return tea.Batch(cmd, errCmd(err))
+			}
+			return tea.Batch(cmd, statusCmd(fmt.Sprintf("keeping %d events", n)))
+		}},
+		command{name: "jetstream: compression", arg: "on or off", run: func(arg string) tea.Cmd {
+			switch arg {
+			case "on", "off":
+			default:
+				return errCmd(fmt.Errorf("compression must be on or off"))
+			}
+			return tea.Batch(m.SetCompression(arg == "on"), statusCmd("compression "+arg))
+		}},
+		command{name: "firehose: verify", arg: "on or off", run: func(arg string) tea.Cmd {
+			switch arg {
+			case "on", "off":
+			default:
+				return errCmd(fmt.Errorf("verification must be on or off"))
+			}
+			if err := m.SetVerify(arg == "on"); err != nil {
+				return errCmd(err)
+			}
+			return statusCmd("verification " + arg)
+		}},
+		command{name: "jetstream: overflow policy", arg: config.OverflowDrop + " or " + config.OverflowBlock, run: func(arg string) tea.Cmd {
+			if err := m.SetOverflow(arg); err != nil {
+				return errCmd(err)
+			}
+			return statusCmd("overflow policy set to " + arg)
+		}},
+	)
+}
+
+func (m *JetStreamView) toggleStats() tea.Cmd {
+	m.showStats = !m.showStats
+	m.SetSize(m.w, m.h)
+	if m.showStats {
+		m.stats.rank()
+		return m.statsTick()
+	}
+	return nil
+}
+
+func (m *JetStreamView) statsTick() tea.Cmd {
+	return tea.Tick(time.Second, func(time.Time) tea.Msg { return statsTickMsg{src: m} })
+}
+
+func (m *JetStreamView) openReplay() tea.Cmd {
+	cmd := m.replay.Open()
+	m.SetSize(m.w, m.h)
+	return cmd
+}
+
+func (m *JetStreamView) openFilter() tea.Cmd {
+	cmd := m.filter.Open(m.session.collections, m.session.dids, m.kinds)
+	m.SetSize(m.w, m.h)
+	return cmd
+}
+
+func (m *JetStreamView) openExport() tea.Cmd {
+	cmd := m.export.Open(fmt.Sprintf("jetstream-%d.ndjson", time.Now().Unix()))
+	m.SetSize(m.w, m.h)
+	return cmd
+}
+
+// CapturingInput reports whether a prompt or the list filter is being
+// edited.
+func (m *JetStreamView) CapturingInput() bool {
+	return m.export.active || m.filter.active || m.replay.active || m.list.SettingFilter()
+}
+
 func (m *JetStreamView) Init() tea.Cmd {
 	return nil
 }
@@ -140,9 +1042,19 @@ func (m *JetStreamView) SetSize(w, h int) {
 	m.w = w
 	m.h = h
 	hh := lipgloss.Height(m.header())
-	if m.ctx == nil {
+	if m.export.active {
+		hh += lipgloss.Height(m.export.View())
+	}
+	if m.filter.active {
+		hh += lipgloss.Height(m.filter.View())
+	}
+	if m.replay.active {
+		hh += lipgloss.Height(m.replay.View())
+	}
+	if m.statusLine() != "" {
 		hh += 1
 	}
+	m.bodyH = h - hh
 	if w > 100 {
 		m.list.SetSize(w/2, h-hh)
 		m.preview.SetSize(w/2, h-hh)
@@ -156,21 +1068,132 @@ func (m *JetStreamView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
 	case jetStreamErrorMsg:
+		// a stopped stream is not reconnected
+		if msg.stream != m.stream || m.state == connStopped || errors.Is(msg.err, context.Canceled) {
+			// resuming reads the dropped events again
+			return m, nil
+		}
+		cmds := make([]tea.Cmd, 0, len(msg.evts)+1)
+		for _, evt := range msg.evts {
+			cmds = append(cmds, m.receive(evt, msg.visible))
+		}
+		if m.state == connReplaying {
+			m.Stop()
+			m.SetSize(m.w, m.h)
+			if errors.Is(msg.err, at.ErrReplayFinished) {
+				return m, tea.Batch(append(cmds, statusCmd("replay finished"))...)
+			}
+			return m, tea.Batch(append(cmds, errCmd(msg.err))...)
+		}
 		slog.Error("JetStream client error", "error", msg.err)
+		cmds = append(cmds, m.reconnect(msg.err))
+		m.SetSize(m.w, m.h)
+		return m, tea.Batch(cmds...)
+
+	case jetStreamConnectedMsg:
+		if msg.stream != m.stream {
+			return m, nil
+		}
+		m.state = connLive
+		m.SetSize(m.w, m.h)
+		return m, m.Listen()
+
+	case jetStreamReconnectMsg:
+		if msg.gen != m.gen || m.state != connReconnecting {
+			return m, nil
+		}
+		return m, m.Resume()
+
+	case jetFilterMatchesMsg:
+		m.list, _ = m.list.Update(msg.matches)
+		if item, ok := m.list.SelectedItem().(eventItem); ok {
+			m.preview.SetEvent(item.event())
+		}
 		return m, nil
 
+	case refilterMsg:
+		return m, m.refilter()
+
+	case verifiedMsg:
+		msg.res.Apply()
+		m.preview.refreshVerify(msg.res.Info)
+		if msg.stream != m.stream {
+			// signatures outlive their run, but its listener is done
+			return m, m.refreshVerified(msg.res.Info)
+		}
+		return m, tea.Batch(m.refreshVerified(msg.res.Info), m.Listen())
+
+	case statsTickMsg:
+		if !m.showStats {
+			return m, nil
+		}
+		m.stats.prune()
+		m.stats.rank()
+		return m, m.statsTick()
+
 	case eventMsg:
-		return m, tea.Batch(
-			m.AddEvent(msg.evt),
-			m.Listen(),
-		)
+		if msg.stream != m.stream {
+			return m, nil
+		}
+		return m, tea.Batch(m.receive(msg.evt, msg.visible), m.Listen())
+	}
+	if m.export.active {
+		req, cmd := m.export.Update(msg)
+		m.SetSize(m.w, m.h)
+		if req != nil {
+			return m, exportCmd(eventsExport(m.visibleEvents()), *req)
+		}
+		return m, cmd
+	}
+	if m.replay.active {
+		cursor, cmd := m.replay.Update(msg)
+		m.SetSize(m.w, m.h)
+		if cursor != nil {
+			return m, m.Replay(*cursor)
+		}
+		return m, cmd
+	}
+	if m.filter.active {
+		f, cmd := m.filter.Update(msg)
+		m.SetSize(m.w, m.h)
+		if f != nil {
+			m.kinds = f.kinds
+			return m, tea.Batch(m.showKinds(), func() tea.Msg { return f.editJetStreamFilterMsg })
+		}
+		return m, cmd
 	}
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if msg.String() == "enter" {
-			if item, ok := m.list.SelectedItem().(jetEventItem); ok {
+		if m.list.SettingFilter() {
+			break
+		}
+		switch msg.String() {
+		case "p":
+			return m, m.togglePause()
+		case "e":
+			return m, m.openExport()
+		case "F":
+			return m, m.openFilter()
+		case "R":
+			return m, m.openReplay()
+		case "s":
+			return m, m.toggleStats()
+		case "d":
+			return m, m.preview.toggleDiff()
+		case "w":
+			return m, m.toggleRecording()
+		case "y", "Y", "J":
+			if item, ok := m.list.SelectedItem().(eventItem); ok {
+				return m, copyEventCmd(msg.String(), item.event())
+			}
+		case "r", "v", "D", "C":
+			if item, ok := m.list.SelectedItem().(eventItem); ok {
+				return m, eventActionCmd(msg.String(), item.event())
+			}
+		case "enter":
+			if item, ok := m.list.SelectedItem().(eventItem); ok {
 				return m, func() tea.Msg {
-					return jetEventSelectedMsg{evt: item.evt}
+					return jetEventSelectedMsg{evt: item.event()}
 				}
 			}
 		}
@@ -178,10 +1201,19 @@ func (m *JetStreamView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	l, cmd := m.list.Update(msg)
 	m.list = l
-	if item, ok := m.list.SelectedItem().(jetEventItem); ok {
-		m.preview.SetEvent(item.evt)
+	if item, ok := m.list.SelectedItem().(eventItem); ok {
+		m.preview.SetEvent(item.event())
 	}
-	return m, cmd
+	return m, m.tagFilter(cmd)
+}
+
+// formatCursor shows a microsecond cursor with the local time it points to,
+// or the sequence number of the firehose.
+func (m *JetStreamView) formatCursor(us int64) string {
+	if m.firehose() {
+		return fmt.Sprintf("seq %d", us)
+	}
+	return fmt.Sprintf("%d %s", us, dimStyle.Render(time.UnixMicro(us).Format(time.DateTime)))
 }
 
 var jetstreamTitleStyle = lipgloss.NewStyle().
@@ -201,42 +1233,157 @@ func (m *JetStreamView) header() string {
 	if len(m.session.dids) > 0 {
 		dids = strings.Join(m.session.dids, ", ")
 	}
+	kinds := dimStyle.Render("all")
+	if len(m.kinds) > 0 {
+		kinds = strings.Join(m.kinds, ", ")
+	}
 	lastCursor := dimStyle.Render("live")
 	if m.session.lastCursor != nil {
-		lastCursor = fmt.Sprintf("%d", *m.session.lastCursor)
+		lastCursor = m.formatCursor(*m.session.lastCursor)
 	}
 
 	title := jetstreamTitleStyle.Render("📡  JetStream Events")
+	if u := m.jc.Firehose(); u != "" {
+		title = jetstreamTitleStyle.Render("🔥  Firehose  " + dimStyle.Render(u))
+	}
 
 	dot := dimStyle.Render("  ·  ")
+	gaps := dimStyle.Render("0")
+	if m.gaps > 0 {
+		gaps = accountStyle.Render(fmt.Sprintf("%d", m.gaps))
+	}
+
+	count := func(n int64) string {
+		if n == 0 {
+			return dimStyle.Render("0")
+		}
+		return accountStyle.Render(fmt.Sprintf("%d", n))
+	}
+	overflow := m.cfg.JetStream.Overflow
+	if overflow == "" {
+		overflow = config.OverflowDrop
+	}
+
+	compression := dimStyle.Render("off")
+	switch {
+	case m.firehose():
+		compression = dimStyle.Render("n/a")
+	case m.jc.Compressed():
+		compression = "zstd"
+	}
+
+	var verified string
+	if m.firehose() {
+		verified = dimStyle.Render("off")
+		if m.jc.Verifying() {
+			valid, invalid, unverified := m.jc.Verifications()
+			verified = fmt.Sprintf("%d ok", valid) + dimStyle.Render(" · ")
+			if unverified > 0 {
+				verified += accountStyle.Render(fmt.Sprintf("%d unverified", unverified))
+			} else {
+				verified += dimStyle.Render("0 unverified")
+			}
+			verified += dimStyle.Render(" · ")
+			if invalid > 0 {
+				verified += queryErrStyle.Render(fmt.Sprintf("%d invalid", invalid))
+			} else {
+				verified += dimStyle.Render("0 invalid")
+			}
+		}
+		verified = dot + dimStyle.Render("verified: ") + verified
+	}
+
+	state := m.connIndicator()
+	if m.paused {
+		state += accountStyle.Render(fmt.Sprintf("  ⏸ paused, %d pending", len(m.pending)))
+	}
+	if m.recorder != nil {
+		state += queryErrStyle.Render(fmt.Sprintf("  ⏺ rec %d", m.recorder.Count())) +
+			dimStyle.Render(" → "+m.recorder.Path())
+	}
+
 	filters := lipgloss.JoinHorizontal(lipgloss.Left,
-		dimStyle.Render(" collections: "), cxs,
+		" ", state,
+		dot, dimStyle.Render("collections: "), cxs,
 		dot, dimStyle.Render("dids: "), dids,
-		dot, dimStyle.Render("cursor: "), lastCursor,
+		dot, dimStyle.Render("kinds: "), kinds,
+	)
+	stats := lipgloss.JoinHorizontal(lipgloss.Left,
+		dimStyle.Render(" cursor: "), lastCursor,
+		dot, dimStyle.Render("gaps: "), gaps,
+		dot, dimStyle.Render("buffer: "), fmt.Sprintf("%d/%d", len(m.buffer), m.cfg.JetStream.Size()),
+		dot, dimStyle.Render("evicted: "), count(int64(m.evicted)),
+		dot, dimStyle.Render("dropped: "), count(m.jc.Dropped()),
+		dot, dimStyle.Render("overflow: "), overflow,
+		dot, dimStyle.Render("compression: "), compression,
+		verified,
 	)
 
-	return lipgloss.JoinVertical(lipgloss.Left, title, filters)
+	return lipgloss.JoinVertical(lipgloss.Left, title, filters, stats)
+}
+
+// statusLine explains how to connect a stream that is not running.
+func (m *JetStreamView) statusLine() string {
+	switch m.state {
+	case connStopped:
+		return dimStyle.Render("  not connected  ·  press ctrl+j to start")
+	case connFailed:
+		return queryErrStyle.Render("  connection failed  ·  press ctrl+j to retry")
+	}
+	return ""
+}
+
+// connIndicator shows the connection state in the header.
+func (m *JetStreamView) connIndicator() string {
+	switch m.state {
+	case connConnecting:
+		return accountStyle.Render("● connecting")
+	case connLive:
+		return didStyle.Render("● live")
+	case connReconnecting:
+		wait := max(time.Until(m.retryAt).Round(time.Second), 0)
+		return accountStyle.Render(fmt.Sprintf("● reconnecting (attempt %d, %s)", m.attempts, wait))
+	case connFailed:
+		return queryErrStyle.Render("● failed")
+	case connReplaying:
+		speed := "max"
+		if m.replaySpeed > 0 {
+			speed = strconv.FormatFloat(m.replaySpeed, 'g', -1, 64) + "x"
+		}
+		return collectionStyle.Render("● replaying recording (" + speed + ")")
+	}
+	return dimStyle.Render("● stopped")
 }
 
 func (m *JetStreamView) View() string {
 	hdr := m.header()
-	status := ""
-	if m.ctx == nil {
-		status = dimStyle.Render("  not connected  ·  press ctrl+j to start")
+	if e := m.export.View(); e != "" {
+		hdr = lipgloss.JoinVertical(lipgloss.Left, hdr, e)
+	}
+	if f := m.filter.View(); f != "" {
+		hdr = lipgloss.JoinVertical(lipgloss.Left, hdr, f)
+	}
+	if r := m.replay.View(); r != "" {
+		hdr = lipgloss.JoinVertical(lipgloss.Left, hdr, r)
 	}
+	status := m.statusLine()
 
-	if m.w > 100 {
-		left := lipgloss.JoinVertical(lipgloss.Left, m.list.View())
+	var body string
+	switch {
+	case m.w > 100:
 		right := m.preview.View()
-		body := lipgloss.JoinHorizontal(lipgloss.Top, left, right)
-		if status != "" {
-			return lipgloss.JoinVertical(lipgloss.Left, hdr, status, body)
+		if m.showStats {
+			right = m.stats.View(m.w/2, m.bodyH, time.Now())
 		}
-		return lipgloss.JoinVertical(lipgloss.Left, hdr, body)
+		body = lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), right)
+	case m.showStats:
+		body = m.stats.View(m.w, m.bodyH, time.Now())
+	default:
+		body = m.list.View()
 	}
 
 	if status != "" {
-		return lipgloss.JoinVertical(lipgloss.Left, hdr, status, m.list.View())
+		return lipgloss.JoinVertical(lipgloss.Left, hdr, status, body)
 	}
-	return lipgloss.JoinVertical(lipgloss.Left, hdr, m.list.View())
+	return lipgloss.JoinVertical(lipgloss.Left, hdr, body)
 }
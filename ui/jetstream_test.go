@@ -0,0 +1,349 @@
+package ui
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluesky-social/jetstream/pkg/models"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gorilla/websocket"
+	"github.com/treethought/attie/at"
+	"github.com/treethought/attie/config"
+)
+
+// newTestJetStream returns a feed reading a local JetStream server, which
+// serves each connection with serve. The queries connections are opened
+// with are sent on the returned channel.
+func newTestJetStream(t *testing.T, serve func(con *websocket.Conn, q url.Values)) (*JetStreamView, <-chan url.Values) {
+	t.Helper()
+	queries := make(chan url.Values, 4)
+	var upgrader websocket.Upgrader
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		con, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer con.Close()
+		queries <- r.URL.Query()
+		serve(con, r.URL.Query())
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := config.Default("")
+	cfg.JetStream.URL = "ws" + strings.TrimPrefix(srv.URL, "http")
+	cfg.JetStream.DisableCompression = true
+	m := NewJetStreamView(cfg, at.NewJetstreamClient(), newRecordHistory())
+	m.SetSize(80, 24)
+	t.Cleanup(func() { m.Stop() })
+	return m, queries
+}
+
+// sendEvents writes commit events at the given times.
+func sendEvents(con *websocket.Conn, times ...int64) {
+	for _, us := range times {
+		evt := fmt.Sprintf(`{"did":"did:plc:abc","time_us":%d,"kind":"commit"}`, us)
+		if err := con.WriteMessage(websocket.TextMessage, []byte(evt)); err != nil {
+			return
+		}
+	}
+}
+
+// nextMsg waits for the next message from the stream of m.
+func nextMsg(t *testing.T, m *JetStreamView) tea.Msg {
+	t.Helper()
+	msgs := make(chan tea.Msg, 1)
+	go func() { msgs <- m.Listen()() }()
+	select {
+	case msg := <-msgs:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message from the stream")
+		return nil
+	}
+}
+
+func nextQuery(t *testing.T, queries <-chan url.Values) url.Values {
+	t.Helper()
+	select {
+	case q := <-queries:
+		return q
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection")
+		return nil
+	}
+}
+
+func TestJetStreamResumeAfterLastEvent(t *testing.T) {
+	m, queries := newTestJetStream(t, func(con *websocket.Conn, q url.Values) {
+		if !q.Has("cursor") {
+			sendEvents(con, 10, 20)
+		}
+		// hold the connection open until the client stops
+		con.ReadMessage()
+	})
+
+	cxs := []string{"app.bsky.feed.post"}
+	m.Start(cxs, nil, nil)
+	if q := nextQuery(t, queries); q.Has("cursor") {
+		t.Errorf("first connection has cursor %q", q.Get("cursor"))
+	}
+	for len(m.Events()) < 2 {
+		m.Update(nextMsg(t, m))
+	}
+	m.Stop()
+
+	m.Resume()
+	q := nextQuery(t, queries)
+	// JetStream replays the event at the cursor, so the next one is asked for
+	if got := q.Get("cursor"); got != "21" {
+		t.Errorf("resumed with cursor %q, want 21", got)
+	}
+	if got := q["wantedCollections"]; len(got) != 1 || got[0] != cxs[0] {
+		t.Errorf("resumed with wantedCollections = %v, want %v", got, cxs)
+	}
+	if got := len(m.Events()); got != 2 {
+		t.Errorf("kept %d events, want 2", got)
+	}
+}
+
+func TestJetStreamReconnectResumesAfterLastEvent(t *testing.T) {
+	m, queries := newTestJetStream(t, func(con *websocket.Conn, q url.Values) {
+		if q.Has("cursor") {
+			// hold the resumed connection open
+			con.ReadMessage()
+			return
+		}
+		sendEvents(con, 10, 20)
+	})
+
+	cxs := []string{"app.bsky.feed.post"}
+	m.Start(cxs, nil, nil)
+	nextQuery(t, queries)
+	for {
+		msg := nextMsg(t, m)
+		m.Update(msg)
+		if _, ok := msg.(jetStreamErrorMsg); ok {
+			break
+		}
+	}
+	if m.state != connReconnecting {
+		t.Fatalf("state after the drop = %v, want reconnecting", m.state)
+	}
+	if got := len(m.Events()); got != 2 {
+		t.Fatalf("buffered %d events, want 2", got)
+	}
+
+	// fired by the backoff timer
+	m.Update(jetStreamReconnectMsg{src: m, gen: m.gen})
+	q := nextQuery(t, queries)
+	if got := q.Get("cursor"); got != "21" {
+		t.Errorf("resumed with cursor %q, want 21", got)
+	}
+	if got := q["wantedCollections"]; len(got) != 1 || got[0] != cxs[0] {
+		t.Errorf("resumed with wantedCollections = %v, want %v", got, cxs)
+	}
+	m.Update(nextMsg(t, m))
+	if m.state != connLive {
+		t.Errorf("state after resuming = %v, want live", m.state)
+	}
+}
+
+func TestJetStreamDropsMessagesOfStoppedRun(t *testing.T) {
+	var conns atomic.Int32
+	m, queries := newTestJetStream(t, func(con *websocket.Conn, q url.Values) {
+		if conns.Add(1) == 1 {
+			sendEvents(con, 10, 20)
+		}
+		// hold the connection open until the client stops
+		con.ReadMessage()
+	})
+
+	m.Start(nil, nil, nil)
+	nextQuery(t, queries)
+	old := m.Listen()
+	m.Stop()
+	m.Clear()
+	m.Start(nil, nil, nil)
+	nextQuery(t, queries)
+
+	// everything the stopped run read is dropped, up to its end
+	for {
+		msgs := make(chan tea.Msg, 1)
+		go func() { msgs <- old() }()
+		var msg tea.Msg
+		select {
+		case msg = <-msgs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stopped run did not end")
+		}
+		if _, cmd := m.Update(msg); cmd != nil {
+			t.Errorf("%T of the stopped run returned a command", msg)
+		}
+		if _, ok := msg.(jetStreamErrorMsg); ok {
+			break
+		}
+	}
+	if got := len(m.Events()); got != 0 {
+		t.Errorf("cleared feed has %d events of the stopped run", got)
+	}
+	if m.state != connConnecting {
+		t.Errorf("state = %v, want connecting", m.state)
+	}
+}
+
+func TestJetStreamBacksOffWhileConnectionsCloseAtOnce(t *testing.T) {
+	var conns atomic.Int32
+	m, queries := newTestJetStream(t, func(con *websocket.Conn, q url.Values) {
+		if conns.Add(1) == 3 {
+			sendEvents(con, 10)
+		}
+	})
+	drop := func() {
+		t.Helper()
+		nextQuery(t, queries)
+		for {
+			msg := nextMsg(t, m)
+			m.Update(msg)
+			if _, ok := msg.(jetStreamErrorMsg); ok {
+				return
+			}
+		}
+	}
+
+	m.Start(nil, nil, nil)
+	drop()
+	m.Update(jetStreamReconnectMsg{src: m, gen: m.gen})
+	drop()
+	// accepted and closed without an event
+	if m.attempts != 2 || m.gaps != 0 {
+		t.Errorf("attempts = %d, gaps = %d, want 2 and 0", m.attempts, m.gaps)
+	}
+	m.Update(jetStreamReconnectMsg{src: m, gen: m.gen})
+	drop()
+	// the event shows the connection worked
+	if m.attempts != 1 || m.gaps != 1 {
+		t.Errorf("after an event attempts = %d, gaps = %d, want 1 and 1", m.attempts, m.gaps)
+	}
+
+	// an error read before stopping does not reconnect
+	m.Update(jetStreamReconnectMsg{src: m, gen: m.gen})
+	nextQuery(t, queries)
+	old := m.Listen()
+	m.Stop()
+	for {
+		msg := old()
+		if _, ok := msg.(jetStreamErrorMsg); ok {
+			if _, cmd := m.Update(msg); cmd != nil || m.state != connStopped {
+				t.Errorf("stopped stream reconnects, state %v", m.state)
+			}
+			break
+		}
+	}
+}
+
+func TestJetStreamEvictsInBatches(t *testing.T) {
+	cfg := config.Default(filepath.Join(t.TempDir(), "config.json"))
+	cfg.JetStream.BufferSize = 10
+	m := NewJetStreamView(cfg, at.NewJetstreamClient(), newRecordHistory())
+	add := func(n int) {
+		for range n {
+			m.AddEvent(&at.Event{Event: models.Event{Did: "did:plc:abc", TimeUS: int64(len(m.Events()) + m.evicted + 1), Kind: models.EventKindCommit}}, true)
+		}
+	}
+
+	add(11)
+	if got := len(m.Events()); got != 11 {
+		t.Fatalf("buffered %d events, want 11 before the batch is full", got)
+	}
+	add(1)
+	if got := len(m.Events()); got != 10 || m.evicted != 2 {
+		t.Fatalf("buffered %d events and evicted %d, want 10 and 2", got, m.evicted)
+	}
+	if newest := m.Events()[len(m.Events())-1]; newest.TimeUS != 12 {
+		t.Errorf("newest event at %d, want 12", newest.TimeUS)
+	}
+
+	if _, err := m.SetBufferSize(4); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.Events()); got != 4 || m.evicted != 8 {
+		t.Errorf("after shrinking buffered %d events and evicted %d, want 4 and 8", got, m.evicted)
+	}
+}
+
+func TestJetStreamKindsFilterTheBuffer(t *testing.T) {
+	cfg := config.Default("")
+	cfg.JetStream.BufferSize = 3
+	m := NewJetStreamView(cfg, at.NewJetstreamClient(), newRecordHistory())
+	add := func(us int64, kind string) {
+		m.AddEvent(&at.Event{Event: models.Event{Did: "did:plc:abc", TimeUS: us, Kind: kind}}, true)
+	}
+	shown := func() []int64 {
+		var got []int64
+		for _, evt := range m.visibleEvents() {
+			got = append(got, evt.TimeUS)
+		}
+		return got
+	}
+
+	if _, err := m.SetKinds([]string{models.EventKindIdentity}); err != nil {
+		t.Fatal(err)
+	}
+	add(1, models.EventKindCommit)
+	add(2, models.EventKindIdentity)
+	add(3, models.EventKindCommit)
+	if got := shown(); !slices.Equal(got, []int64{2}) {
+		t.Errorf("identity events shown = %v, want [2]", got)
+	}
+	if got := len(m.Events()); got != 3 {
+		t.Errorf("buffered %d events, want all 3", got)
+	}
+	if got := *m.session.lastCursor; got != 3 {
+		t.Errorf("cursor = %d, want 3", got)
+	}
+
+	if _, err := m.SetKinds(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := shown(); !slices.Equal(got, []int64{1, 2, 3}) {
+		t.Errorf("all events shown = %v, want [1 2 3]", got)
+	}
+	// evicted events leave the list too
+	add(4, models.EventKindAccount)
+	m.cfg.JetStream.BufferSize = 2
+	m.trim()
+	if got := shown(); !slices.Equal(got, []int64{3, 4}) {
+		t.Errorf("shown after evicting = %v, want [3 4]", got)
+	}
+}
+
+func TestJetStreamReplayChecksCursor(t *testing.T) {
+	m, queries := newTestJetStream(t, func(con *websocket.Conn, q url.Values) {
+		con.ReadMessage()
+	})
+
+	// seconds rather than microseconds
+	if msg, ok := m.Replay(1725519626)().(statusMsg); !ok || msg.err == nil {
+		t.Fatalf("cursor before JetStream replayed: %+v", msg)
+	}
+	m.Replay(1725519626134432)
+	if got := nextQuery(t, queries).Get("cursor"); got != "1725519626134432" {
+		t.Errorf("replayed from cursor %q, want 1725519626134432", got)
+	}
+	m.Stop()
+
+	// firehose cursors are sequence numbers
+	m.jc.SetFirehose(m.cfg.JetStream.URL)
+	m.Replay(15)
+	if got := nextQuery(t, queries).Get("cursor"); got != "15" {
+		t.Errorf("replayed the firehose from cursor %q, want 15", got)
+	}
+}
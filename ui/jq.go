@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+const (
+	// jqTimeout bounds a query, which may not terminate, e.g. repeat(.)
+	jqTimeout = 2 * time.Second
+	// results kept from a query before it is stopped
+	maxJQResults = 1000
+	// bytes of formatted results shown
+	maxJQOutput = 1 << 20
+)
+
+// errJQLimit stops a query that produced maxJQResults.
+var errJQLimit = fmt.Errorf("stopped after %d results", maxJQResults)
+
+// runJQ evaluates a jq expression against a decoded JSON value and returns
+// the values it produces, up to maxJQResults. The results so far are
+// returned with errJQLimit beyond it.
+func runJQ(ctx context.Context, expr string, v any) ([]any, error) {
+	q, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	code, err := gojq.Compile(q)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	iter := code.RunWithContext(ctx, v)
+	for {
+		res, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := res.(error); ok {
+			if err, ok := err.(*gojq.HaltError); ok && err.Value() == nil {
+				break
+			}
+			if errors.Is(err, context.DeadlineExceeded) {
+				return out, fmt.Errorf("query timed out after %s", jqTimeout)
+			}
+			return out, err
+		}
+		if len(out) == maxJQResults {
+			return out, errJQLimit
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+// formatJQResults renders results the way the jq CLI does, one indented
+// JSON document per result, up to maxJQOutput bytes.
+func formatJQResults(results []any) string {
+	var s strings.Builder
+	for i, r := range results {
+		if s.Len() >= maxJQOutput {
+			fmt.Fprintf(&s, "\n… %d more results", len(results)-i)
+			break
+		}
+		if i > 0 {
+			s.WriteString("\n")
+		}
+		data, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			s.WriteString(fmt.Sprintf("error marshaling result: %v", err))
+			continue
+		}
+		if len(data) > maxJQOutput {
+			data = append(data[:maxJQOutput:maxJQOutput], "\n… truncated"...)
+		}
+		s.Write(data)
+	}
+	return s.String()
+}
+
+// decodeJSON decodes data into the generic form expected by gojq and
+// lookupPath.
+func decodeJSON(data []byte) (any, error) {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
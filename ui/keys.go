@@ -0,0 +1,30 @@
+package ui
+
+import (
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/viewport"
+)
+
+// The bubbles keymaps page up with b, which attie uses to bookmark, and
+// page down with d, which deletes bookmarks and toggles diffs of events.
+// These remove them, paging stays on pgup/pgdown and the other default keys.
+
+func freeListKeys(km *list.KeyMap) {
+	km.PrevPage.SetKeys("left", "h", "pgup", "u")
+}
+
+// freeListD removes d from the paging keys of lists that bind it.
+func freeListD(km *list.KeyMap) {
+	km.NextPage.SetKeys("right", "l", "pgdown", "f")
+}
+
+func freeTableKeys(km *table.KeyMap) {
+	km.PageUp.SetKeys("pgup")
+	km.PageUp.SetHelp("pgup", "page up")
+}
+
+func freeViewportKeys(km *viewport.KeyMap) {
+	km.PageUp.SetKeys("pgup")
+	km.HalfPageDown.SetKeys("ctrl+d")
+}
@@ -0,0 +1,138 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/treethought/attie/config"
+)
+
+// webTarget is the item to open in a web app, built from the current view.
+type webTarget struct {
+	did        string
+	handle     string
+	collection string
+	rkey       string
+	// record is the decoded record value, if known
+	record any
+}
+
+func (t webTarget) uri() string {
+	uri := "at://" + t.did
+	if t.collection != "" {
+		uri += "/" + t.collection
+		if t.rkey != "" {
+			uri += "/" + t.rkey
+		}
+	}
+	return uri
+}
+
+// expand fills in a URL template, reporting false when it refers to a
+// record field that is not available. Values are escaped as path segments.
+func (t webTarget) expand(tmpl string) (string, bool) {
+	handle := t.handle
+	if handle == "" || handle == syntax.HandleInvalid.String() {
+		handle = t.did
+	}
+	escaped := webTarget{
+		did:        url.PathEscape(t.did),
+		collection: url.PathEscape(t.collection),
+		rkey:       url.PathEscape(t.rkey),
+	}
+	s := strings.NewReplacer(
+		"{did}", escaped.did,
+		"{handle}", url.PathEscape(handle),
+		"{collection}", escaped.collection,
+		"{rkey}", escaped.rkey,
+		"{uri}", escaped.uri(),
+	).Replace(tmpl)
+
+	for {
+		start := strings.Index(s, "{record.")
+		if start < 0 {
+			return s, true
+		}
+		end := strings.Index(s[start:], "}")
+		if end < 0 {
+			return s, true
+		}
+		path := s[start+len("{record.") : start+end]
+		v, ok := lookupPath(t.record, path)
+		if !ok {
+			return "", false
+		}
+		s = s[:start] + url.PathEscape(formatScalar(v)) + s[start+end+1:]
+	}
+}
+
+// webURL maps the target to a URL using the frontend with the longest
+// matching NSID prefix that has a template for the target's kind. The
+// configured frontends are tried before the defaults, so that a configured
+// prefix overrides a longer default one.
+func webURL(configured []config.Frontend, t webTarget) (string, error) {
+	if t.did == "" {
+		return "", fmt.Errorf("nothing to open")
+	}
+	if u, ok := matchFrontend(configured, t); ok {
+		return u, nil
+	}
+	if u, ok := matchFrontend(config.DefaultFrontends, t); ok {
+		return u, nil
+	}
+	return "", fmt.Errorf("no frontend configured for %s", t.uri())
+}
+
+// matchFrontend expands the template of the frontend with the longest
+// matching prefix.
+func matchFrontend(frontends []config.Frontend, t webTarget) (string, bool) {
+	sorted := append([]config.Frontend{}, frontends...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+	for _, f := range sorted {
+		if !strings.HasPrefix(t.collection, f.Prefix) {
+			continue
+		}
+		tmpl := f.Profile
+		switch {
+		case t.rkey != "":
+			tmpl = f.Record
+		case t.collection != "" && f.Collection != "":
+			tmpl = f.Collection
+		}
+		if tmpl == "" {
+			continue
+		}
+		if u, ok := t.expand(tmpl); ok {
+			return u, true
+		}
+	}
+	return "", false
+}
+
+// openURLCmd opens the URL in the default browser.
+func openURLCmd(url string) tea.Cmd {
+	return func() tea.Msg {
+		var cmd *exec.Cmd
+		switch runtime.GOOS {
+		case "darwin":
+			cmd = exec.Command("open", url)
+		case "windows":
+			cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+		default:
+			cmd = exec.Command("xdg-open", url)
+		}
+		if err := cmd.Start(); err != nil {
+			return statusMsg{err: fmt.Errorf("failed to open %s: %w", url, err)}
+		}
+		go cmd.Wait()
+		return statusMsg{text: "opened " + url}
+	}
+}
@@ -0,0 +1,241 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/list"
+)
+
+// fieldQuery is a parsed content filter, e.g. `text~"outage" subject.uri=at://...`.
+// All terms must match for a value to match.
+type fieldQuery []queryTerm
+
+type queryTerm struct {
+	path  string
+	op    string
+	value string
+}
+
+// parseFieldQuery parses a space separated list of terms. A term is either
+// `path=value` (exact), `path!=value` (not equal), `path~value` (case
+// insensitive contains) or a bare word matched anywhere in the raw JSON.
+// Values may be double quoted to include spaces.
+func parseFieldQuery(s string) (fieldQuery, error) {
+	tokens, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	q := make(fieldQuery, 0, len(tokens))
+	for _, tok := range tokens {
+		q = append(q, parseTerm(tok))
+	}
+	return q, nil
+}
+
+func parseTerm(tok string) queryTerm {
+	for i := 0; i < len(tok); i++ {
+		switch tok[i] {
+		case '~':
+			return queryTerm{path: tok[:i], op: "~", value: unquote(tok[i+1:])}
+		case '!':
+			if i+1 < len(tok) && tok[i+1] == '=' {
+				return queryTerm{path: tok[:i], op: "!=", value: unquote(tok[i+2:])}
+			}
+		case '=':
+			return queryTerm{path: tok[:i], op: "=", value: unquote(tok[i+1:])}
+		case '"':
+			// quoted bare word
+			return queryTerm{value: unquote(tok)}
+		}
+	}
+	return queryTerm{value: tok}
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// splitQuery splits on whitespace outside of double quotes.
+func splitQuery(s string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\\' && inQuote && i+1 < len(s):
+			cur.WriteByte(ch)
+			i++
+			cur.WriteByte(s[i])
+		case ch == '"':
+			inQuote = !inQuote
+			cur.WriteByte(ch)
+		case (ch == ' ' || ch == '\t') && !inQuote:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteByte(ch)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens, nil
+}
+
+// Match reports whether the decoded JSON value v satisfies every term.
+// raw is the text bare words are matched against.
+func (q fieldQuery) Match(v any, raw string) bool {
+	lraw := strings.ToLower(raw)
+	for _, t := range q {
+		if t.path == "" {
+			if !strings.Contains(lraw, strings.ToLower(t.value)) {
+				return false
+			}
+			continue
+		}
+		got, ok := lookupPath(v, t.path)
+		s := formatScalar(got)
+		switch t.op {
+		case "=":
+			if !ok || s != t.value {
+				return false
+			}
+		case "!=":
+			if ok && s == t.value {
+				return false
+			}
+		case "~":
+			if !ok || !strings.Contains(strings.ToLower(s), strings.ToLower(t.value)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// lookupPath resolves a dot separated path such as `subject.uri` or
+// `facets.0.features` against a decoded JSON value.
+func lookupPath(v any, path string) (any, bool) {
+	if path == "" || path == "." {
+		return v, true
+	}
+	for _, part := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[part]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// formatScalar renders a JSON value as plain text, encoding objects and
+// arrays as compact JSON.
+func formatScalar(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(b)
+	}
+}
+
+// filterValueSep separates the title portion of a FilterValue from the JSON
+// document that field terms are evaluated against.
+const filterValueSep = "\x00"
+
+// fieldQueryFilter is a list.FilterFunc that evaluates the filter input as a
+// fieldQuery. Targets are expected in the form "<label>\x00<json>".
+func fieldQueryFilter(term string, targets []string) []list.Rank {
+	q, err := parseFieldQuery(term)
+	if err != nil {
+		return nil
+	}
+	ranks := []list.Rank{}
+	for i, target := range targets {
+		if q.Match(decodeFilterDoc(target), target) {
+			ranks = append(ranks, list.Rank{Index: i})
+		}
+	}
+	return ranks
+}
+
+// decodeFilterDoc decodes the JSON document of a filter target.
+func decodeFilterDoc(target string) any {
+	_, doc, _ := strings.Cut(target, filterValueSep)
+	var v any
+	if doc != "" {
+		_ = json.Unmarshal([]byte(doc), &v)
+	}
+	return v
+}
+
+// filterDocs caches decoded filter targets between runs of a list filter,
+// so that a list re-filtered as items arrive only decodes the new ones.
+type filterDocs struct {
+	mu   sync.Mutex
+	docs map[string]any
+}
+
+// filter is a list.FilterFunc like fieldQueryFilter, reusing the documents
+// decoded by the previous run.
+func (c *filterDocs) filter(term string, targets []string) []list.Rank {
+	q, err := parseFieldQuery(term)
+	if err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// only the current targets are kept
+	docs := make(map[string]any, len(targets))
+	ranks := []list.Rank{}
+	for i, target := range targets {
+		v, ok := c.docs[target]
+		if !ok {
+			v = decodeFilterDoc(target)
+		}
+		docs[target] = v
+		if q.Match(v, target) {
+			ranks = append(ranks, list.Rank{Index: i})
+		}
+	}
+	c.docs = docs
+	return ranks
+}
@@ -15,7 +15,19 @@ type RecordView struct {
 }
 
 func NewRecordView(preview bool) *RecordView {
-	return &RecordView{ContentView: newContentView(preview)}
+	rv := &RecordView{ContentView: newContentView(preview)}
+	rv.exporter = rv.exportData
+	return rv
+}
+
+func (rv *RecordView) exportData() (exportData, string) {
+	name := "record.json"
+	if uri, err := syntax.ParseATURI(rv.record.Uri); err == nil {
+		name = fmt.Sprintf("%s-%s.json", uri.Collection(), uri.RecordKey())
+	}
+	d := recordsExport([]*at.Record{rv.record}, formatPaths(defaultColumns))
+	d.single = true
+	return d, name
 }
 
 func (rv *RecordView) buildHeader() string {
@@ -33,25 +45,71 @@ func (rv *RecordView) buildHeader() string {
 	return headerStyle.Render(header)
 }
 
-func (rv *RecordView) SetRecord(record *at.Record) {
+func (rv *RecordView) SetRecord(record *at.Record) tea.Cmd {
 	rv.record = record
 	if record == nil || record.Value == nil {
-		rv.Set("", "")
-		return
+		return rv.Set("", "")
 	}
 	data, err := json.MarshalIndent(record.Value, "", "  ")
 	if err != nil {
 		data = fmt.Appendf([]byte{}, "error marshaling record: %v", err)
 	}
-	rv.Set(rv.buildHeader(), string(data))
+	return rv.Set(rv.buildHeader(), string(data))
 }
 
 func (rv *RecordView) Init() tea.Cmd {
 	return rv.initVP()
 }
 func (rv *RecordView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && !rv.CapturingInput() && rv.record != nil {
+		switch msg.String() {
+		case "y", "Y", "J":
+			return rv, copyRecordCmd(msg.String(), rv.record)
+		}
+	}
 	return rv, rv.updateVP(msg)
 }
+
+func (rv *RecordView) Commands() []command {
+	return append(recordCommands(func() *at.Record { return rv.record }), rv.contentCommands("record")...)
+}
+
+// recordCommands are the palette commands for the selected record.
+func recordCommands(selected func() *at.Record) []command {
+	copyCmd := func(key string) func(string) tea.Cmd {
+		return func(string) tea.Cmd {
+			if rec := selected(); rec != nil {
+				return copyRecordCmd(key, rec)
+			}
+			return nil
+		}
+	}
+	return []command{
+		{name: "copy uri", run: copyCmd("y")},
+		{name: "copy cid", run: copyCmd("Y")},
+		{name: "copy json", run: copyCmd("J")},
+	}
+}
+
+// copyRecordCmd copies the record URI (y), CID (Y) or JSON value (J).
+func copyRecordCmd(key string, rec *at.Record) tea.Cmd {
+	switch key {
+	case "y":
+		return copyCmd("URI", rec.Uri)
+	case "Y":
+		return copyCmd("CID", rec.Cid)
+	case "J":
+		if rec.Value == nil {
+			return copyCmd("JSON", "")
+		}
+		data, err := json.MarshalIndent(rec.Value, "", "  ")
+		if err != nil {
+			return errCmd(err)
+		}
+		return copyCmd("JSON", string(data))
+	}
+	return nil
+}
 func (rv *RecordView) View() string {
 	return rv.renderVP()
 }
@@ -0,0 +1,149 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// replayPrompt asks for the point in time to replay the stream from.
+type replayPrompt struct {
+	input  textinput.Model
+	active bool
+	err    string
+}
+
+func newReplayPrompt() replayPrompt {
+	ti := textinput.New()
+	ti.Prompt = "replay from> "
+	ti.Placeholder = "15m ago | 2024-09-05 14:30 | 1725519626134432"
+	return replayPrompt{input: ti}
+}
+
+func (p *replayPrompt) Open() tea.Cmd {
+	p.active = true
+	p.err = ""
+	p.input.SetValue("")
+	return p.input.Focus()
+}
+
+func (p *replayPrompt) Close() {
+	p.active = false
+	p.input.Blur()
+}
+
+// Update handles input while the prompt is open, returning the cursor once
+// it is submitted.
+func (p *replayPrompt) Update(msg tea.Msg) (*int64, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "esc":
+			p.Close()
+			return nil, nil
+		case "enter":
+			cursor, err := parseReplayFrom(p.input.Value(), time.Now())
+			if err != nil {
+				p.err = err.Error()
+				return nil, nil
+			}
+			p.Close()
+			return &cursor, nil
+		}
+	}
+	var cmd tea.Cmd
+	p.input, cmd = p.input.Update(msg)
+	return nil, cmd
+}
+
+func (p *replayPrompt) View() string {
+	if !p.active {
+		return ""
+	}
+	if p.err != "" {
+		return p.input.View() + "\n" + queryErrStyle.Render(p.err)
+	}
+	return p.input.View()
+}
+
+var replayLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseReplayFrom converts a relative duration ("15m ago", "2h", "1d"), a
+// local or RFC 3339 timestamp, or a raw unix microsecond cursor to a
+// JetStream cursor. Raw cursors are passed on as is, since they are firehose
+// sequence numbers when reading the firehose; Replay checks them for the
+// source.
+func parseReplayFrom(s string, now time.Time) (int64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("enter a duration, timestamp or cursor")
+	}
+	if us, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if us > now.UnixMicro() {
+			return 0, fmt.Errorf("cursor is in the future")
+		}
+		return us, nil
+	}
+
+	var t time.Time
+	rel := strings.TrimSpace(strings.TrimSuffix(s, "ago"))
+	if d, err := parseDuration(rel); err == nil {
+		t = now.Add(-d)
+	} else {
+		for _, layout := range replayLayouts {
+			if t, err = time.ParseInLocation(layout, s, time.Local); err == nil {
+				break
+			}
+		}
+		if t.IsZero() {
+			return 0, fmt.Errorf("invalid duration, timestamp or cursor %q", s)
+		}
+	}
+	if t.After(now) {
+		return 0, fmt.Errorf("%s is in the future", t.Format(time.DateTime))
+	}
+	return t.UnixMicro(), nil
+}
+
+// parseDuration extends time.ParseDuration with whole days, e.g. "1d".
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative duration")
+	}
+	return d, nil
+}
+
+// ParseSpeed parses a replay speed such as "2x", "0.5" or "max", where max
+// replays a recording as fast as it can be read.
+func ParseSpeed(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "max" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid speed %q, expected e.g. 2x, 0.5x or max", s)
+	}
+	return speed, nil
+}
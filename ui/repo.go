@@ -18,6 +18,7 @@ var (
 	valueStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("255"))
 	collectionStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
 	dimStyle        = lipgloss.NewStyle().Faint(true)
+	keyStyle        = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("205"))
 )
 
 type CollectionList struct {
@@ -52,6 +53,7 @@ func NewCollectionList(collections []string) *CollectionList {
 	del.SetHeight(1)
 
 	l := list.New(items, del, 80, 20)
+	freeListKeys(&l.KeyMap)
 	l.SetShowTitle(false)
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(true)
@@ -159,7 +161,38 @@ func (r *RepoView) Init() tea.Cmd {
 	return nil
 }
 
+// SelectedCollection returns the NSID of the highlighted collection.
+func (r *RepoView) SelectedCollection() string {
+	if item, ok := r.clist.list.SelectedItem().(CollectionListItem); ok {
+		return item.Name
+	}
+	return ""
+}
+
+func (r *RepoView) Commands() []command {
+	if r.repo == nil {
+		return nil
+	}
+	return []command{
+		{name: "copy did", run: func(string) tea.Cmd { return copyCmd("DID", r.repo.Did) }},
+		{name: "copy handle", run: func(string) tea.Cmd { return copyCmd("handle", r.repo.Handle) }},
+	}
+}
+
+// CapturingInput reports whether the collection filter is being edited.
+func (r *RepoView) CapturingInput() bool {
+	return r.clist.list.SettingFilter()
+}
+
 func (r *RepoView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok && r.repo != nil && !r.CapturingInput() {
+		switch msg.String() {
+		case "y":
+			return r, copyCmd("DID", r.repo.Did)
+		case "Y":
+			return r, copyCmd("handle", r.repo.Handle)
+		}
+	}
 	clist, cmd := r.clist.Update(msg)
 	r.clist = clist.(*CollectionList)
 	return r, cmd
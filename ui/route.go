@@ -0,0 +1,160 @@
+package ui
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/treethought/attie/config"
+)
+
+type queryKind int
+
+const (
+	queryIdentifier queryKind = iota
+	queryATURI
+	queryCID
+)
+
+// parsedQuery is palette or command line input normalized to a handle/DID,
+// AT URI or CID.
+type parsedQuery struct {
+	kind  queryKind
+	value string
+}
+
+// parseQuery accepts a handle, DID, AT URI, CID, or a web app URL that maps
+// back to an AT URI through the configured frontends.
+func parseQuery(frontends []config.Frontend, raw string) (parsedQuery, error) {
+	q := strings.TrimPrefix(strings.TrimSpace(raw), "@")
+	if q == "" {
+		return parsedQuery{}, fmt.Errorf("input cannot be empty")
+	}
+	if strings.HasPrefix(q, "http://") || strings.HasPrefix(q, "https://") {
+		uri, err := webToATURI(frontends, q)
+		if err != nil {
+			return parsedQuery{}, err
+		}
+		q = uri
+	}
+	if id, err := syntax.ParseAtIdentifier(q); err == nil {
+		return parsedQuery{kind: queryIdentifier, value: id.String()}, nil
+	}
+	if uri, err := syntax.ParseATURI(q); err == nil {
+		if uri.Collection() == "" {
+			return parsedQuery{kind: queryIdentifier, value: uri.Authority().String()}, nil
+		}
+		return parsedQuery{kind: queryATURI, value: uri.String()}, nil
+	}
+	if cid, err := syntax.ParseCID(q); err == nil {
+		return parsedQuery{kind: queryCID, value: cid.String()}, nil
+	}
+	return parsedQuery{}, fmt.Errorf("must use handle, DID, AT URI, CID or web URL")
+}
+
+// webToATURI translates a web app URL back to an AT URI by matching it
+// against the frontend URL templates. URLs embedding an AT URI, as
+// explorers like pdsls do, are used directly.
+func webToATURI(frontends []config.Frontend, raw string) (string, error) {
+	if i := strings.Index(raw, "at://"); i >= 0 {
+		uri, err := url.PathUnescape(raw[i:])
+		if err != nil {
+			return "", fmt.Errorf("invalid url: %w", err)
+		}
+		return strings.TrimSuffix(uri, "/"), nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid url: %w", err)
+	}
+	target := u.Host + strings.TrimSuffix(u.Path, "/")
+
+	for _, f := range frontends {
+		// a full NSID prefix implies the collection of its templates
+		implied := ""
+		if f.Prefix != "" && !strings.HasSuffix(f.Prefix, ".") {
+			implied = f.Prefix
+		}
+		for _, tmpl := range []string{f.Record, f.Collection, f.Profile} {
+			m, ok := matchTemplate(tmpl, target)
+			if !ok {
+				continue
+			}
+			if m["uri"] != "" {
+				return m["uri"], nil
+			}
+			id := m["id"]
+			collection := m["collection"]
+			switch tmpl {
+			case f.Record:
+				if collection == "" {
+					collection = implied
+				}
+				if collection == "" || m["rkey"] == "" {
+					continue
+				}
+				return fmt.Sprintf("at://%s/%s/%s", id, collection, m["rkey"]), nil
+			case f.Collection:
+				if collection == "" {
+					collection = implied
+				}
+				if collection != "" {
+					return fmt.Sprintf("at://%s/%s", id, collection), nil
+				}
+			}
+			return "at://" + id, nil
+		}
+	}
+	return "", fmt.Errorf("no frontend matches %s", raw)
+}
+
+var templatePlaceholders = map[string]string{
+	"{did}":        `(?P<id>[^/]+)`,
+	"{handle}":     `(?P<id>[^/]+)`,
+	"{collection}": `(?P<collection>[^/]+)`,
+	"{rkey}":       `(?P<rkey>[^/]+)`,
+	"{uri}":        `(?P<uri>at://.+)`,
+}
+
+var placeholderRe = regexp.MustCompile(`\{[a-z.]+\}`)
+
+// matchTemplate matches a URL (host and path, without scheme) against a
+// frontend template, returning the captured placeholders.
+func matchTemplate(tmpl, target string) (map[string]string, bool) {
+	if tmpl == "" || strings.Contains(tmpl, "{record.") {
+		return nil, false
+	}
+	tmpl = strings.TrimPrefix(strings.TrimPrefix(tmpl, "https://"), "http://")
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	last := 0
+	for _, loc := range placeholderRe.FindAllStringIndex(tmpl, -1) {
+		pattern.WriteString(regexp.QuoteMeta(tmpl[last:loc[0]]))
+		group, ok := templatePlaceholders[tmpl[loc[0]:loc[1]]]
+		if !ok {
+			return nil, false
+		}
+		pattern.WriteString(group)
+		last = loc[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(strings.TrimSuffix(tmpl[last:], "/")))
+	pattern.WriteString("$")
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, false
+	}
+	m := re.FindStringSubmatch(target)
+	if m == nil {
+		return nil, false
+	}
+	out := map[string]string{}
+	for i, name := range re.SubexpNames() {
+		if name != "" && m[i] != "" {
+			out[name] = m[i]
+		}
+	}
+	return out, true
+}
@@ -1,16 +1,47 @@
 package ui
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
-	"github.com/bluesky-social/indigo/atproto/syntax"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/treethought/attie/at"
+	"github.com/treethought/attie/config"
 )
 
+const (
+	maxSuggestions = 8
+	typeaheadDelay = 200 * time.Millisecond
+)
+
+type suggestionKind string
+
+const (
+	suggestBookmark suggestionKind = "bookmark"
+	suggestRecent   suggestionKind = "recent"
+	suggestActor    suggestionKind = "actor"
+	suggestCommand  suggestionKind = "command"
+)
+
+// suggestion is a completion offered below the palette input.
+type suggestion struct {
+	value string
+	// title is shown in place of value when set
+	title  string
+	detail string
+	kind   suggestionKind
+	cmd    *command
+}
+
+// commandPrefix switches the palette to command mode.
+const commandPrefix = ">"
+
 type CommandPallete struct {
 	ti      textinput.Model
 	err     string
@@ -18,15 +49,45 @@ type CommandPallete struct {
 	spinner spinner.Model
 	width   int
 	height  int
+
+	client      *at.Client
+	frontends   []config.Frontend
+	state       *config.State
+	actors      []suggestion
+	suggestions []suggestion
+	// selected suggestion, -1 when the input itself is used
+	selected     int
+	typeaheadSeq int
+
+	commands []command
+	// pending is the command whose argument is being entered
+	pending *command
+}
+
+func NewCommandPallete(client *at.Client, frontends []config.Frontend, state *config.State) *CommandPallete {
+	return &CommandPallete{client: client, frontends: frontends, state: state, selected: -1}
+}
+
+type typeaheadTickMsg struct {
+	seq   int
+	query string
+}
+
+type typeaheadResultMsg struct {
+	query  string
+	actors []at.Actor
 }
 
 func (c *CommandPallete) Init() tea.Cmd {
 	c.ti = textinput.New()
-	c.ti.Placeholder = "Enter handle, DID, or AT URI"
+	c.ti.Placeholder = "Enter handle, DID, AT URI, CID or web URL, or > for commands"
+	c.pending = nil
 	c.ti.Focus()
 	c.ti.Width = 60
 	c.spinner = spinner.New()
 	c.spinner.Spinner = spinner.Dot
+	c.actors = nil
+	c.refreshSuggestions()
 	return textinput.Blink
 }
 
@@ -48,36 +109,223 @@ func (c *CommandPallete) SetSize(w, h int) {
 	}
 }
 
+// CapturingInput is always true, the palette is a text input.
+func (c *CommandPallete) CapturingInput() bool {
+	return true
+}
+
+// SetCommands sets the commands available in command mode.
+func (c *CommandPallete) SetCommands(cmds []command) {
+	c.commands = cmds
+}
+
+func (c *CommandPallete) commandMode() bool {
+	return c.pending == nil && strings.HasPrefix(c.ti.Value(), commandPrefix)
+}
+
+// refreshSuggestions rebuilds suggestions from bookmarks and history
+// matching the input, followed by any typeahead results.
+func (c *CommandPallete) refreshSuggestions() {
+	q := strings.ToLower(strings.TrimSpace(c.ti.Value()))
+	var out []suggestion
+	defer func() {
+		c.suggestions = out
+		if c.selected >= len(out) {
+			c.selected = len(out) - 1
+		}
+	}()
+	if c.pending != nil {
+		return
+	}
+	if c.commandMode() {
+		pattern := strings.TrimSpace(strings.TrimPrefix(q, commandPrefix))
+		for _, cmd := range matchCommands(c.commands, pattern) {
+			out = append(out, suggestion{value: cmd.name, detail: cmd.arg, kind: suggestCommand, cmd: &cmd})
+			if len(out) >= maxSuggestions {
+				break
+			}
+		}
+		return
+	}
+	seen := map[string]bool{}
+	add := func(s suggestion) {
+		if seen[s.value] || len(out) >= maxSuggestions {
+			return
+		}
+		seen[s.value] = true
+		out = append(out, s)
+	}
+	matches := func(fields ...string) bool {
+		for _, f := range fields {
+			if strings.Contains(strings.ToLower(f), q) {
+				return true
+			}
+		}
+		return false
+	}
+	if c.state != nil {
+		for _, b := range c.state.Bookmarks {
+			if matches(b.URI, b.Label, b.Note) {
+				add(suggestion{value: b.URI, title: b.Label, detail: b.Note, kind: suggestBookmark})
+			}
+		}
+		for _, v := range c.state.History {
+			if matches(v.URI, v.Label) {
+				add(suggestion{value: v.URI, title: v.Label, kind: suggestRecent})
+			}
+		}
+	}
+	if q != "" {
+		for _, a := range c.actors {
+			add(a)
+		}
+	}
+}
+
+// scheduleTypeahead debounces actor search while typing.
+func (c *CommandPallete) scheduleTypeahead() tea.Cmd {
+	c.typeaheadSeq++
+	q := strings.TrimSpace(c.ti.Value())
+	if c.client == nil || q == "" || strings.Contains(q, ":") || c.pending != nil || c.commandMode() {
+		c.actors = nil
+		return nil
+	}
+	seq := c.typeaheadSeq
+	return tea.Tick(typeaheadDelay, func(time.Time) tea.Msg {
+		return typeaheadTickMsg{seq: seq, query: q}
+	})
+}
+
+func (c *CommandPallete) searchActors(q string) tea.Cmd {
+	return func() tea.Msg {
+		actors, err := c.client.SearchActorsTypeahead(context.Background(), strings.TrimPrefix(q, "@"), maxSuggestions)
+		if err != nil {
+			slog.Warn("Actor typeahead failed", "query", q, "error", err)
+			return nil
+		}
+		return typeaheadResultMsg{query: q, actors: actors}
+	}
+}
+
+func (c *CommandPallete) submit(val string) tea.Cmd {
+	q, err := parseQuery(c.frontends, val)
+	if err != nil {
+		c.err = err.Error()
+		return nil
+	}
+	c.err = ""
+	c.loading = true
+	return func() tea.Msg {
+		slog.Info("Looking up query", "query", q.value)
+		return searchSubmitMsg{query: q}
+	}
+}
+
+// runCommand runs a command, first prompting for its argument if needed.
+func (c *CommandPallete) runCommand(cmd command) tea.Cmd {
+	if cmd.arg != "" && c.pending == nil {
+		c.pending = &cmd
+		c.ti.Prompt = cmd.name + ": "
+		c.ti.Placeholder = cmd.arg
+		c.ti.SetValue("")
+		c.selected = -1
+		c.refreshSuggestions()
+		return nil
+	}
+	arg := strings.TrimSpace(c.ti.Value())
+	if cmd.arg != "" && !cmd.optional && arg == "" {
+		c.err = cmd.arg + " is required"
+		return nil
+	}
+	c.err = ""
+	return func() tea.Msg {
+		return runCommandMsg{cmd: cmd, arg: arg}
+	}
+}
+
 func (c *CommandPallete) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
+	case typeaheadTickMsg:
+		if msg.seq != c.typeaheadSeq {
+			return c, nil
+		}
+		return c, c.searchActors(msg.query)
+
+	case typeaheadResultMsg:
+		if msg.query != strings.TrimSpace(c.ti.Value()) {
+			return c, nil
+		}
+		c.actors = make([]suggestion, len(msg.actors))
+		for i, a := range msg.actors {
+			c.actors[i] = suggestion{value: a.Handle, detail: a.DisplayName, kind: suggestActor}
+		}
+		c.refreshSuggestions()
+		return c, nil
+
 	case tea.KeyMsg:
 		switch msg.String() {
-		case "enter":
-			val := c.ti.Value()
-			if val == "" {
-				c.err = "Input cannot be empty"
-				return c, nil
+		case "up", "ctrl+p":
+			if c.selected >= 0 {
+				c.selected--
+			}
+			return c, nil
+		case "down", "ctrl+n":
+			if c.selected < len(c.suggestions)-1 {
+				c.selected++
 			}
-			id, err := syntax.ParseAtIdentifier(val)
-			if err != nil {
-				c.err = fmt.Sprintf("Must use handle, DID or AT URI: %s", err.Error())
+			return c, nil
+		case "tab":
+			if c.selected >= 0 && !c.commandMode() {
+				c.ti.SetValue(c.suggestions[c.selected].value)
+				c.ti.CursorEnd()
+				c.selected = -1
+				c.refreshSuggestions()
+				return c, c.scheduleTypeahead()
+			}
+			return c, nil
+		case "esc":
+			switch {
+			case c.pending != nil:
+				return c, c.Init()
+			case c.ti.Value() != "":
+				c.ti.SetValue("")
+				c.err = ""
+				c.selected = -1
+				c.refreshSuggestions()
 				return c, nil
 			}
-			c.err = ""
-			c.loading = true
-			return c, func() tea.Msg {
-				slog.Info("Looking up identifier", "id", id.String())
-				return searchSubmitMsg{identifier: id}
+			return c, func() tea.Msg { return paletteClosedMsg{} }
+		case "enter":
+			if c.pending != nil {
+				return c, c.runCommand(*c.pending)
+			}
+			if c.commandMode() {
+				i := max(c.selected, 0)
+				if i >= len(c.suggestions) {
+					c.err = "No matching command"
+					return c, nil
+				}
+				return c, c.runCommand(*c.suggestions[i].cmd)
 			}
+			val := strings.TrimSpace(c.ti.Value())
+			if c.selected >= 0 {
+				val = c.suggestions[c.selected].value
+			}
+			return c, c.submit(val)
 		}
-
 	}
 
 	var cmds []tea.Cmd
+	prev := c.ti.Value()
 	ti, tcmd := c.ti.Update(msg)
 	c.ti = ti
 	cmds = append(cmds, tcmd)
+	if c.ti.Value() != prev {
+		c.selected = -1
+		c.refreshSuggestions()
+		cmds = append(cmds, c.scheduleTypeahead())
+	}
 
 	sp, scmd := c.spinner.Update(msg)
 	c.spinner = sp
@@ -86,11 +334,42 @@ func (c *CommandPallete) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return c, tea.Batch(cmds...)
 }
 
-var searchStyle = lipgloss.NewStyle().Padding(1, 2).Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("62"))
+var (
+	searchStyle             = lipgloss.NewStyle().Padding(1, 2).Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("62"))
+	suggestionStyle         = lipgloss.NewStyle().PaddingLeft(2)
+	selectedSuggestionStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("205")).Bold(true)
+)
+
+func (c *CommandPallete) suggestionsView() string {
+	if len(c.suggestions) == 0 {
+		return ""
+	}
+	lines := make([]string, len(c.suggestions))
+	for i, s := range c.suggestions {
+		title := s.value
+		if s.title != "" {
+			title = s.title
+		}
+		rest := ""
+		if s.detail != "" {
+			rest += "  " + dimStyle.Render(s.detail)
+		}
+		rest += "  " + labelStyle.Render(string(s.kind))
+		if i == c.selected {
+			lines[i] = selectedSuggestionStyle.Render("> "+title) + rest
+			continue
+		}
+		lines[i] = suggestionStyle.Render(title + rest)
+	}
+	return strings.Join(lines, "\n")
+}
 
 func (c *CommandPallete) View() string {
 	// make centered search box
 	s := c.ti.View()
+	if sv := c.suggestionsView(); sv != "" {
+		s += "\n\n" + sv
+	}
 	if c.err != "" {
 		s += fmt.Sprintf("\nError: %s", c.err)
 	} else if c.loading {
@@ -0,0 +1,342 @@
+package ui
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/treethought/attie/at"
+)
+
+// statsTickMsg refreshes the stats panel while no events arrive.
+type statsTickMsg struct {
+	src *JetStreamView
+}
+
+const (
+	// seconds of history kept for rates and sparklines
+	statsWindow = 60
+	// seconds averaged for the current rates
+	rateWindow = 10
+	// DIDs kept by prune, the most active ones
+	maxTrackedDIDs = 10_000
+	// DIDs listed in the panel
+	shownDIDs = 5
+)
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// statBucket holds the events received in one wall clock second.
+type statBucket struct {
+	sec         int64
+	events      int
+	lag         time.Duration
+	collections map[string]int
+	// bytes read from the connection and after decoding
+	received int64
+	decoded  int64
+}
+
+// streamStats tracks throughput and activity of a stream for the stats
+// panel.
+type streamStats struct {
+	start       time.Time
+	total       int
+	lastLag     time.Duration
+	buckets     [statsWindow]statBucket
+	collections map[string]int
+	ops         map[string]int
+	dids        map[string]int
+	// topDIDs are the most active DIDs as of the last rank
+	topDIDs []countEntry
+	// byte totals of the client when last sampled
+	received int64
+	decoded  int64
+}
+
+func newStreamStats() *streamStats {
+	return &streamStats{
+		collections: map[string]int{},
+		ops:         map[string]int{},
+		dids:        map[string]int{},
+	}
+}
+
+func (s *streamStats) add(evt *at.Event, now time.Time) {
+	if s.start.IsZero() {
+		s.start = now
+	}
+	s.total++
+	b := s.bucket(now.Unix())
+	b.events++
+	s.lastLag = now.Sub(time.UnixMicro(evt.TimeUS))
+	b.lag += s.lastLag
+
+	op := evt.Kind
+	if evt.Commit != nil {
+		op = evt.Commit.Operation
+		s.collections[evt.Commit.Collection]++
+		b.collections[evt.Commit.Collection]++
+	}
+	s.ops[op]++
+
+	s.dids[evt.Did]++
+	// pruned on the stats tick, or here while the panel is hidden and
+	// does not tick
+	if len(s.dids) >= 4*maxTrackedDIDs {
+		s.prune()
+	}
+}
+
+// prune keeps the most active DIDs, so that the top DIDs are counted
+// without tracking every DID of the network. DIDs that become active later
+// start over and are kept once they outrank the others.
+func (s *streamStats) prune() {
+	if len(s.dids) <= maxTrackedDIDs {
+		return
+	}
+	top := topCounts(s.dids, maxTrackedDIDs)
+	s.dids = make(map[string]int, len(top))
+	for _, e := range top {
+		s.dids[e.key] = e.n
+	}
+}
+
+// rank updates the top DIDs listed in the panel. It runs on the stats tick
+// rather than on every render, as there may be many DIDs to rank.
+func (s *streamStats) rank() {
+	s.topDIDs = topCounts(s.dids, shownDIDs)
+}
+
+// addBytes samples the byte totals of the client, attributing what was read
+// since the last sample to the current second.
+func (s *streamStats) addBytes(received, decoded int64, now time.Time) {
+	if received < s.received || decoded < s.decoded {
+		// the client was reset
+		s.received, s.decoded = 0, 0
+	}
+	b := s.bucket(now.Unix())
+	b.received += received - s.received
+	b.decoded += decoded - s.decoded
+	s.received, s.decoded = received, decoded
+}
+
+// bucket returns the bucket for sec, resetting it if it held an older
+// second.
+func (s *streamStats) bucket(sec int64) *statBucket {
+	b := &s.buckets[sec%statsWindow]
+	if b.sec != sec {
+		*b = statBucket{sec: sec, collections: map[string]int{}}
+	}
+	return b
+}
+
+// series returns per second values for the window ending before now,
+// oldest first.
+func (s *streamStats) series(now time.Time, value func(b statBucket) float64) []float64 {
+	out := make([]float64, statsWindow)
+	cur := now.Unix()
+	for i := range out {
+		sec := cur - statsWindow + int64(i)
+		if b := s.buckets[sec%statsWindow]; b.sec == sec {
+			out[i] = value(b)
+		}
+	}
+	return out
+}
+
+// rate returns the events per second over the last complete seconds,
+// optionally for a single collection.
+func (s *streamStats) rate(now time.Time, collection string) float64 {
+	n := 0
+	cur := now.Unix()
+	for sec := cur - rateWindow; sec < cur; sec++ {
+		b := s.buckets[sec%statsWindow]
+		if b.sec != sec {
+			continue
+		}
+		if collection == "" {
+			n += b.events
+		} else {
+			n += b.collections[collection]
+		}
+	}
+	return float64(n) / rateWindow
+}
+
+// byteRate returns the bytes per second read from the connection and after
+// decoding over the last complete seconds.
+func (s *streamStats) byteRate(now time.Time) (received, decoded float64) {
+	cur := now.Unix()
+	for sec := cur - rateWindow; sec < cur; sec++ {
+		if b := s.buckets[sec%statsWindow]; b.sec == sec {
+			received += float64(b.received)
+			decoded += float64(b.decoded)
+		}
+	}
+	return received / rateWindow, decoded / rateWindow
+}
+
+func eventsPerSec(b statBucket) float64 {
+	return float64(b.events)
+}
+
+func bytesPerSec(b statBucket) float64 {
+	return float64(b.received)
+}
+
+func avgLag(b statBucket) float64 {
+	if b.events == 0 {
+		return 0
+	}
+	return float64(b.lag) / float64(b.events)
+}
+
+type countEntry struct {
+	key string
+	n   int
+}
+
+// topCounts returns up to limit entries with the highest counts, highest
+// first and ties by key, without sorting all of m.
+func topCounts(m map[string]int, limit int) []countEntry {
+	if limit <= 0 {
+		return nil
+	}
+	// only the top entries seen so far are kept, the lowest ranked first
+	h := make(countHeap, 0, min(limit, len(m)))
+	for k, n := range m {
+		e := countEntry{k, n}
+		if len(h) < limit {
+			heap.Push(&h, e)
+		} else if ranksAbove(e, h[0]) {
+			h[0] = e
+			heap.Fix(&h, 0)
+		}
+	}
+	sort.Slice(h, func(i, j int) bool { return ranksAbove(h[i], h[j]) })
+	return h
+}
+
+// ranksAbove orders entries by count, then key.
+func ranksAbove(a, b countEntry) bool {
+	if a.n != b.n {
+		return a.n > b.n
+	}
+	return a.key < b.key
+}
+
+// countHeap is a min-heap of entries by rank.
+type countHeap []countEntry
+
+func (h countHeap) Len() int           { return len(h) }
+func (h countHeap) Less(i, j int) bool { return ranksAbove(h[j], h[i]) }
+func (h countHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *countHeap) Push(x any)        { *h = append(*h, x.(countEntry)) }
+func (h *countHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// sparkline renders values scaled to their maximum, one block per value.
+// Negative values, such as the lag of events timed ahead of the local
+// clock, show as the lowest block.
+func sparkline(values []float64, width int) string {
+	if width < len(values) {
+		values = values[len(values)-width:]
+	}
+	peak := 0.0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if peak > 0 {
+			i = int(min(max(v, 0), peak) / peak * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+func (s *streamStats) View(w, h int, now time.Time) string {
+	if s.total == 0 {
+		return dimStyle.Render("  no events yet")
+	}
+	section := func(title string) string { return headerStyle.Render(title) }
+	label := func(l string) string { return labelStyle.Render(fmt.Sprintf("  %-10s", l)) }
+	sparkW := max(w-26, 10)
+
+	lags := s.series(now, avgLag)
+	lines := []string{
+		section("Throughput"),
+		label("events/s") + valueStyle.Render(fmt.Sprintf("%8.1f ", s.rate(now, ""))) +
+			collectionStyle.Render(sparkline(s.series(now, eventsPerSec), sparkW)),
+		label("lag") + valueStyle.Render(fmt.Sprintf("%8s ", s.lastLag.Round(time.Millisecond))) +
+			opStyle.Render(sparkline(lags, sparkW)),
+		label("total") + valueStyle.Render(fmt.Sprintf("%8d ", s.total)) +
+			dimStyle.Render("in "+now.Sub(s.start).Round(time.Second).String()),
+		"",
+	}
+	if s.received > 0 {
+		received, decoded := s.byteRate(now)
+		ratio := float64(s.decoded) / float64(s.received)
+		lines = append(lines,
+			section("Bandwidth"),
+			label("wire/s")+valueStyle.Render(fmt.Sprintf("%8s ", formatBytes(received)))+
+				collectionStyle.Render(sparkline(s.series(now, bytesPerSec), sparkW)),
+			label("decoded/s")+valueStyle.Render(fmt.Sprintf("%8s ", formatBytes(decoded))),
+			label("total")+valueStyle.Render(fmt.Sprintf("%8s ", formatBytes(float64(s.received))))+
+				dimStyle.Render(fmt.Sprintf("of %s decoded, %.1fx", formatBytes(float64(s.decoded)), ratio)),
+			"",
+		)
+	}
+	lines = append(lines, section("Operations"))
+	var ops []string
+	for _, e := range topCounts(s.ops, len(s.ops)) {
+		ops = append(ops, opStyle.Render(e.key)+" "+valueStyle.Render(fmt.Sprintf("%d", e.n)))
+	}
+	lines = append(lines, "  "+strings.Join(ops, dimStyle.Render("  ·  ")), "", section("Collections")+dimStyle.Render("  events/s  total"))
+	for _, e := range topCounts(s.collections, 8) {
+		lines = append(lines, fmt.Sprintf("  %-34s %s %s",
+			truncate(e.key, 34),
+			valueStyle.Render(fmt.Sprintf("%8.1f", s.rate(now, e.key))),
+			dimStyle.Render(fmt.Sprintf("%6d", e.n)),
+		))
+	}
+	lines = append(lines, "", section("Top DIDs"))
+	for _, e := range s.topDIDs {
+		lines = append(lines, "  "+didStyle.Render(fmt.Sprintf("%-34s", truncate(e.key, 34)))+" "+dimStyle.Render(fmt.Sprintf("%6d", e.n)))
+	}
+	if len(lines) > h {
+		lines = lines[:h]
+	}
+	return lipgloss.NewStyle().Width(w).MaxWidth(w).Render(strings.Join(lines, "\n"))
+}
+
+// formatBytes shows a byte count with a binary unit.
+func formatBytes(n float64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	i := 0
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f%s", n, units[i])
+	}
+	return fmt.Sprintf("%.1f%s", n, units[i])
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-1] + "…"
+}
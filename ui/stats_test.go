@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSparklineNegative(t *testing.T) {
+	got := sparkline([]float64{-5, 0, 5, 10}, 10)
+	want := "▁▁▄█"
+	if got != want {
+		t.Errorf("sparkline = %q, want %q", got, want)
+	}
+	if got := sparkline([]float64{-3, -1}, 10); got != "▁▁" {
+		t.Errorf("sparkline of negative values = %q, want %q", got, "▁▁")
+	}
+	if n := utf8.RuneCountInString(sparkline(make([]float64, 60), 20)); n != 20 {
+		t.Errorf("sparkline width = %d, want 20", n)
+	}
+}
+
+func TestStatsPruneKeepsTopDIDs(t *testing.T) {
+	s := newStreamStats()
+	for i := range maxTrackedDIDs + 100 {
+		s.dids[fmt.Sprintf("did:plc:%d", i)] = 1
+	}
+	s.dids["did:plc:busy"] = 50
+	s.prune()
+	if len(s.dids) != maxTrackedDIDs {
+		t.Fatalf("kept %d DIDs, want %d", len(s.dids), maxTrackedDIDs)
+	}
+	if s.dids["did:plc:busy"] != 50 {
+		t.Errorf("busiest DID count = %d, want 50", s.dids["did:plc:busy"])
+	}
+}
+
+func TestTopCounts(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 5, "c": 3, "d": 5, "e": 2, "f": 3}
+	got := topCounts(m, 4)
+	want := []countEntry{{"b", 5}, {"d", 5}, {"c", 3}, {"f", 3}}
+	if !slices.Equal(got, want) {
+		t.Errorf("top 4 = %v, want %v", got, want)
+	}
+	if got := topCounts(m, 10); len(got) != len(m) || got[len(got)-1] != (countEntry{"a", 1}) {
+		t.Errorf("top 10 = %v, want all %d ranked", got, len(m))
+	}
+}
@@ -0,0 +1,390 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bluesky-social/indigo/atproto/syntax"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/treethought/attie/at"
+	"github.com/treethought/attie/config"
+)
+
+const (
+	maxColumnWidth = 40
+	minColumnWidth = 4
+)
+
+// Pseudo column paths that resolve against the record rather than its value.
+const (
+	columnRkey = "@rkey"
+	columnCid  = "@cid"
+	columnUri  = "@uri"
+)
+
+var defaultColumns = []config.Column{{Path: columnRkey}, {Path: "createdAt"}}
+
+// RecordsTable renders records as rows of a table. Each row keeps a
+// reference to the record it was produced from. Columns can be selected
+// with left/right, resized with </> and sorted with s.
+type RecordsTable struct {
+	table    table.Model
+	columns  []string
+	widths   []int
+	rows     [][]string
+	records  []*at.Record
+	selCol   int
+	sortCol  int
+	sortDesc bool
+	w, h     int
+}
+
+func NewRecordsTable() *RecordsTable {
+	t := table.New(table.WithFocused(true))
+	freeTableKeys(&t.KeyMap)
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(lipgloss.Color("62")).
+		BorderBottom(true).
+		Bold(true)
+	styles.Selected = styles.Selected.
+		Foreground(lipgloss.Color("229")).
+		Background(lipgloss.Color("57"))
+	t.SetStyles(styles)
+	return &RecordsTable{table: t, sortCol: -1}
+}
+
+// SetData replaces the table contents. rows and records must be the same
+// length. widths may be nil or contain zeros to size columns to content.
+func (t *RecordsTable) SetData(columns []string, widths []int, rows [][]string, records []*at.Record) {
+	t.columns = columns
+	t.widths = make([]int, len(columns))
+	for i, c := range columns {
+		if i < len(widths) && widths[i] > 0 {
+			t.widths[i] = widths[i]
+			continue
+		}
+		w := lipgloss.Width(c)
+		for _, row := range rows {
+			if i < len(row) {
+				w = max(w, min(lipgloss.Width(row[i]), maxColumnWidth))
+			}
+		}
+		t.widths[i] = w
+	}
+	t.rows = rows
+	t.records = records
+	t.selCol = min(t.selCol, max(len(columns)-1, 0))
+	if t.sortCol >= len(columns) {
+		t.sortCol = -1
+	}
+	t.sortRows()
+	t.rebuild()
+	t.table.SetCursor(0)
+}
+
+// Widths returns the current column widths.
+func (t *RecordsTable) Widths() []int {
+	return t.widths
+}
+
+func (t *RecordsTable) rebuild() {
+	cols := make([]table.Column, len(t.columns))
+	for i, c := range t.columns {
+		title := c
+		if i == t.sortCol {
+			if t.sortDesc {
+				title += " ↓"
+			} else {
+				title += " ↑"
+			}
+		}
+		if i == t.selCol {
+			title = "▸" + title
+		}
+		cols[i] = table.Column{Title: title, Width: t.widths[i]}
+	}
+	trows := make([]table.Row, len(t.rows))
+	for i, r := range t.rows {
+		trows[i] = table.Row(r)
+	}
+	// clear rows first so stale rows never render against new columns
+	t.table.SetRows(nil)
+	t.table.SetColumns(cols)
+	t.table.SetRows(trows)
+}
+
+// sortRows orders rows and their records by the sort column, comparing
+// numerically when both cells are numbers.
+func (t *RecordsTable) sortRows() {
+	if t.sortCol < 0 {
+		return
+	}
+	idx := make([]int, len(t.rows))
+	for i := range idx {
+		idx[i] = i
+	}
+	// numbers sort before text, so the order stays total in columns of
+	// both; NaN has no order among numbers and sorts as text
+	type sortKey struct {
+		num   float64
+		isNum bool
+		text  string
+	}
+	keys := make([]sortKey, len(t.rows))
+	for i, row := range t.rows {
+		k := sortKey{text: row[t.sortCol]}
+		if f, err := strconv.ParseFloat(k.text, 64); err == nil && !math.IsNaN(f) {
+			k.num, k.isNum = f, true
+		}
+		keys[i] = k
+	}
+	less := func(x, y sortKey) bool {
+		if x.isNum != y.isNum {
+			return x.isNum
+		}
+		if x.isNum {
+			return x.num < y.num
+		}
+		return x.text < y.text
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		x, y := keys[idx[a]], keys[idx[b]]
+		if t.sortDesc {
+			return less(y, x)
+		}
+		return less(x, y)
+	})
+	rows := make([][]string, len(idx))
+	records := make([]*at.Record, len(idx))
+	for i, j := range idx {
+		rows[i] = t.rows[j]
+		records[i] = t.records[j]
+	}
+	t.rows = rows
+	t.records = records
+}
+
+// SelectedRecord returns the record the selected row was produced from.
+func (t *RecordsTable) SelectedRecord() *at.Record {
+	i := t.table.Cursor()
+	if i < 0 || i >= len(t.records) {
+		return nil
+	}
+	return t.records[i]
+}
+
+func (t *RecordsTable) SetSize(w, h int) {
+	t.w, t.h = w, h
+	t.table.SetWidth(w)
+	t.table.SetHeight(h)
+}
+
+func (t *RecordsTable) Init() tea.Cmd {
+	return nil
+}
+
+// update handles column selection, resizing and sorting. It returns
+// resized as true when column widths changed.
+func (t *RecordsTable) update(msg tea.Msg) (cmd tea.Cmd, resized bool) {
+	if msg, ok := msg.(tea.KeyMsg); ok && len(t.columns) > 0 {
+		switch msg.String() {
+		case "left", "h":
+			t.selCol = max(t.selCol-1, 0)
+			t.rebuild()
+			return nil, false
+		case "right", "l":
+			t.selCol = min(t.selCol+1, len(t.columns)-1)
+			t.rebuild()
+			return nil, false
+		case "<":
+			t.widths[t.selCol] = max(t.widths[t.selCol]-2, minColumnWidth)
+			t.rebuild()
+			return nil, true
+		case ">":
+			t.widths[t.selCol] += 2
+			t.rebuild()
+			return nil, true
+		case "s":
+			if t.sortCol == t.selCol {
+				if t.sortDesc {
+					t.sortCol = -1
+				}
+				t.sortDesc = !t.sortDesc
+			} else {
+				t.sortCol = t.selCol
+				t.sortDesc = false
+			}
+			t.sortRows()
+			t.rebuild()
+			return nil, false
+		}
+	}
+	t.table, cmd = t.table.Update(msg)
+	return cmd, false
+}
+
+func (t *RecordsTable) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	cmd, _ := t.update(msg)
+	return t, cmd
+}
+
+func (t *RecordsTable) View() string {
+	if len(t.table.Rows()) == 0 {
+		return dimStyle.Render("No rows")
+	}
+	return t.table.View()
+}
+
+// tabulateRecords builds rows from JSON path columns.
+func tabulateRecords(columns []config.Column, records []*at.Record) ([]string, []int, [][]string) {
+	names := make([]string, len(columns))
+	widths := make([]int, len(columns))
+	for i, c := range columns {
+		names[i] = c.Path
+		widths[i] = c.Width
+	}
+	rows := make([][]string, len(records))
+	for r, rec := range records {
+		var doc any
+		if rec.Value != nil {
+			doc, _ = decodeJSON(*rec.Value)
+		}
+		row := make([]string, len(columns))
+		for i, c := range columns {
+			row[i] = recordCell(rec, doc, c.Path)
+		}
+		rows[r] = row
+	}
+	return names, widths, rows
+}
+
+func recordCell(rec *at.Record, doc any, path string) string {
+	switch path {
+	case columnRkey:
+		uri, err := syntax.ParseATURI(rec.Uri)
+		if err != nil {
+			return ""
+		}
+		return uri.RecordKey().String()
+	case columnCid:
+		return rec.Cid
+	case columnUri:
+		return rec.Uri
+	}
+	v, ok := lookupPath(doc, path)
+	if !ok {
+		return ""
+	}
+	return tableCell(v)
+}
+
+// parseColumns parses a comma separated list of column paths, keeping
+// widths of columns that already exist.
+func parseColumns(s string, existing []config.Column) []config.Column {
+	widths := map[string]int{}
+	for _, c := range existing {
+		widths[c.Path] = c.Width
+	}
+	var cols []config.Column
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		cols = append(cols, config.Column{Path: p, Width: widths[p]})
+	}
+	return cols
+}
+
+func formatColumns(cols []config.Column) string {
+	return strings.Join(formatPaths(cols), ", ")
+}
+
+func formatPaths(cols []config.Column) []string {
+	paths := make([]string, len(cols))
+	for i, c := range cols {
+		paths[i] = c.Path
+	}
+	return paths
+}
+
+// projectRecords evaluates a jq expression against each record value and
+// tabulates the results. Objects produce one column per key, any other
+// result a single "value" column. A record may produce zero or many rows.
+// It stops with the error of ctx once ctx is done.
+func projectRecords(ctx context.Context, expr string, records []*at.Record) ([]string, [][]string, []*at.Record, error) {
+	type result struct {
+		v   any
+		rec *at.Record
+	}
+	var results []result
+	colSet := map[string]bool{}
+	var cols []string
+	scalar := false
+	for _, rec := range records {
+		if rec.Value == nil {
+			continue
+		}
+		doc, err := decodeJSON(*rec.Value)
+		if err != nil {
+			continue
+		}
+		out, err := runJQ(ctx, expr, doc)
+		if err != nil && !errors.Is(err, errJQLimit) {
+			return nil, nil, nil, err
+		}
+		for _, v := range out {
+			results = append(results, result{v: v, rec: rec})
+			obj, ok := v.(map[string]any)
+			if !ok {
+				scalar = true
+				continue
+			}
+			keys := make([]string, 0, len(obj))
+			for k := range obj {
+				if !colSet[k] {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				colSet[k] = true
+				cols = append(cols, k)
+			}
+		}
+	}
+	if scalar {
+		cols = append(cols, "value")
+	}
+
+	rows := make([][]string, len(results))
+	recs := make([]*at.Record, len(results))
+	for i, res := range results {
+		row := make([]string, len(cols))
+		if obj, ok := res.v.(map[string]any); ok {
+			for c, k := range cols {
+				if v, ok := obj[k]; ok {
+					row[c] = tableCell(v)
+				}
+			}
+		} else {
+			row[len(cols)-1] = tableCell(res.v)
+		}
+		rows[i] = row
+		recs[i] = res.rec
+	}
+	return cols, rows, recs, nil
+}
+
+// tableCell renders a value on a single line.
+func tableCell(v any) string {
+	return strings.ReplaceAll(formatScalar(v), "\n", " ")
+}
@@ -0,0 +1,41 @@
+package ui
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/treethought/attie/at"
+)
+
+func TestSortRows(t *testing.T) {
+	tbl := NewRecordsTable()
+	cells := []string{"2", "10", "1a", "1.0", "NaN", "3", "9", "1"}
+	rows := make([][]string, len(cells))
+	records := make([]*at.Record, len(cells))
+	for i, c := range cells {
+		rows[i] = []string{c}
+		records[i] = &at.Record{Cid: c}
+	}
+	tbl.SetData([]string{"n"}, nil, rows, records)
+
+	sorted := func(desc bool) []string {
+		tbl.sortCol, tbl.sortDesc = 0, desc
+		tbl.sortRows()
+		var got []string
+		for i, row := range tbl.rows {
+			if tbl.records[i].Cid != row[0] {
+				t.Fatalf("row %q is paired with record %q", row[0], tbl.records[i].Cid)
+			}
+			got = append(got, row[0])
+		}
+		return got
+	}
+	// equal values keep their order both ways, and numbers come before
+	// text, which NaN sorts as
+	if got, want := sorted(false), []string{"1.0", "1", "2", "3", "9", "10", "1a", "NaN"}; !slices.Equal(got, want) {
+		t.Errorf("ascending = %v, want %v", got, want)
+	}
+	if got, want := sorted(true), []string{"NaN", "1a", "10", "9", "3", "2", "1.0", "1"}; !slices.Equal(got, want) {
+		t.Errorf("descending = %v, want %v", got, want)
+	}
+}
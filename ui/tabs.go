@@ -0,0 +1,168 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/treethought/attie/at"
+	"github.com/treethought/attie/config"
+)
+
+const maxTabs = 9
+
+// tab is a workspace with its own context, views and navigation. The palette
+// and bookmarks are shared by all tabs.
+type tab struct {
+	repoView     *RepoView
+	rlist        *RecordsList
+	recordView   *RecordView
+	jetEventView *JetStreamEventView
+	active       tea.Model
+	loading      bool
+	actx         *AppContext
+
+	jetstream      *JetStreamView
+	jetSreamActive bool
+
+	// view to return to when leaving the bookmarks view
+	bookmarksReturn tea.Model
+
+	// view opened from a JetStream event, which returns to the stream
+	streamReturn tea.Model
+
+	// TODO better nav handling
+	// this currently only used for going back from jetstreamevent
+	lastView tea.Model
+}
+
+func newTab(cfg *config.Config, active tea.Model, history *recordHistory) *tab {
+	return &tab{
+		repoView:     NewRepoView(),
+		rlist:        NewRecordsList(cfg, nil),
+		recordView:   NewRecordView(false),
+		jetEventView: NewJetEventView(false, history),
+		active:       active,
+		actx:         &AppContext{},
+		jetstream:    NewJetStreamView(cfg, at.NewJetstreamClient(), history),
+	}
+}
+
+func (t *tab) setSize(w, h int) {
+	t.repoView.SetSize(w, h)
+	t.rlist.SetSize(w, h)
+	t.recordView.SetSize(w, h)
+	t.jetstream.SetSize(w, h)
+	t.jetEventView.SetSize(w, h)
+}
+
+// title names the tab after what it is showing.
+func (t *tab) title() string {
+	if t.jetSreamActive || t.active == t.jetEventView {
+		return "jetstream"
+	}
+	title := "search"
+	if t.actx.identity != nil {
+		title = t.actx.identity.Handle.String()
+		if t.actx.collection != "" && t.active != t.repoView {
+			title += "/" + t.actx.collection
+		}
+	}
+	if n := t.jetstream.Unread(); n > 0 {
+		title += fmt.Sprintf(" (%d new)", n)
+	}
+	return title
+}
+
+// tabMsg delivers the result of an async command to the tab that started
+// it, which may no longer be the current one.
+type tabMsg struct {
+	tab *tab
+	msg tea.Msg
+}
+
+// inTab tags the message produced by cmd with the current tab.
+func (a *App) inTab(cmd tea.Cmd) tea.Cmd {
+	t := a.tab
+	return func() tea.Msg {
+		return tabMsg{tab: t, msg: cmd()}
+	}
+}
+
+func (a *App) tabIndex(t *tab) int {
+	for i, tt := range a.tabs {
+		if tt == t {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *App) openTab() tea.Cmd {
+	if len(a.tabs) >= maxTabs {
+		return errCmd(fmt.Errorf("at most %d tabs can be open", maxTabs))
+	}
+	t := newTab(a.cfg, a.search, a.history)
+	a.tabs = append(a.tabs, t)
+	a.tab = t
+	a.search.loading = false
+	return tea.Batch(a.resizeChildren(), a.search.Init())
+}
+
+func (a *App) switchTab(i int) tea.Cmd {
+	if i < 0 || i >= len(a.tabs) || a.tabs[i] == a.tab {
+		return nil
+	}
+	a.tab = a.tabs[i]
+	if a.active == a.search {
+		return a.search.Init()
+	}
+	return nil
+}
+
+// closeTab closes the current tab and stops its stream. The last tab is
+// never closed.
+func (a *App) closeTab() tea.Cmd {
+	if len(a.tabs) == 1 {
+		return errCmd(fmt.Errorf("cannot close the last tab"))
+	}
+	i := a.tabIndex(a.tab)
+	a.tab.jetstream.Stop()
+	a.tab.jetstream.StopRecording()
+	a.tabs = append(a.tabs[:i], a.tabs[i+1:]...)
+	a.tab = a.tabs[min(i, len(a.tabs)-1)]
+	if a.active == a.search {
+		return tea.Batch(a.resizeChildren(), a.search.Init())
+	}
+	return a.resizeChildren()
+}
+
+var (
+	tabStyle       = lipgloss.NewStyle().Padding(0, 1).Foreground(lipgloss.Color("241"))
+	activeTabStyle = lipgloss.NewStyle().Padding(0, 1).Bold(true).Foreground(lipgloss.Color("205"))
+)
+
+// tabBarHeight is the height taken by the tab bar, which is only shown with
+// more than one tab open.
+func (a *App) tabBarHeight() int {
+	if len(a.tabs) < 2 {
+		return 0
+	}
+	return 1
+}
+
+func (a *App) tabBar() string {
+	if len(a.tabs) < 2 {
+		return ""
+	}
+	names := make([]string, len(a.tabs))
+	for i, t := range a.tabs {
+		style := tabStyle
+		if t == a.tab {
+			style = activeTabStyle
+		}
+		names[i] = style.Render(fmt.Sprintf("%d %s", i+1, t.title()))
+	}
+	return lipgloss.NewStyle().MaxWidth(a.w).Render(strings.Join(names, dimStyle.Render("│")))
+}
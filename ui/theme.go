@@ -0,0 +1,86 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// theme is the set of colors the shared styles are built from.
+type theme struct {
+	accent lipgloss.TerminalColor
+	border lipgloss.TerminalColor
+	label  lipgloss.TerminalColor
+	value  lipgloss.TerminalColor
+	green  lipgloss.TerminalColor
+	info   lipgloss.TerminalColor
+	warn   lipgloss.TerminalColor
+}
+
+var themes = map[string]theme{
+	"default": {
+		accent: lipgloss.Color("205"),
+		border: lipgloss.Color("62"),
+		label:  lipgloss.Color("241"),
+		value:  lipgloss.Color("255"),
+		green:  lipgloss.Color("42"),
+		info:   lipgloss.Color("39"),
+		warn:   lipgloss.Color("214"),
+	},
+	"ocean": {
+		accent: lipgloss.Color("39"),
+		border: lipgloss.Color("24"),
+		label:  lipgloss.Color("245"),
+		value:  lipgloss.Color("255"),
+		green:  lipgloss.Color("80"),
+		info:   lipgloss.Color("117"),
+		warn:   lipgloss.Color("221"),
+	},
+	"mono": {
+		accent: lipgloss.NoColor{},
+		border: lipgloss.NoColor{},
+		label:  lipgloss.NoColor{},
+		value:  lipgloss.NoColor{},
+		green:  lipgloss.NoColor{},
+		info:   lipgloss.NoColor{},
+		warn:   lipgloss.NoColor{},
+	},
+}
+
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyTheme rebuilds the shared styles from the named theme. Headers that
+// are already rendered keep their colors until they are rebuilt.
+func applyTheme(name string) error {
+	if name == "" {
+		name = "default"
+	}
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf("unknown theme %q, available: %v", name, themeNames())
+	}
+	headerStyle = lipgloss.NewStyle().Bold(true).Foreground(t.accent)
+	labelStyle = lipgloss.NewStyle().Foreground(t.label)
+	valueStyle = lipgloss.NewStyle().Foreground(t.value)
+	collectionStyle = lipgloss.NewStyle().Foreground(t.green)
+	keyStyle = lipgloss.NewStyle().Bold(true).Foreground(t.accent)
+	opStyle = lipgloss.NewStyle().Foreground(t.accent)
+	didStyle = lipgloss.NewStyle().Foreground(t.green)
+	identityStyle = lipgloss.NewStyle().Foreground(t.info)
+	accountStyle = lipgloss.NewStyle().Foreground(t.warn)
+	diffAddStyle = lipgloss.NewStyle().Foreground(t.green)
+	jetstreamTitleStyle = jetstreamTitleStyle.Foreground(t.accent).BorderForeground(t.border)
+	searchStyle = searchStyle.BorderForeground(t.border)
+	selectedSuggestionStyle = lipgloss.NewStyle().Foreground(t.accent).Bold(true)
+	tabStyle = tabStyle.Foreground(t.label)
+	activeTabStyle = activeTabStyle.Foreground(t.accent)
+	return nil
+}